@@ -7,30 +7,63 @@ import (
 	"os"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 
 	"github.com/BeralaWoolies/GameboyGo/pkg/gb"
 )
 
 var cpuprofile *string = flag.String("cpuprofile", "", "write cpu profile to `file`")
 var memprofile *string = flag.String("memprofile", "", "write memory profile to `file`")
-var rom *string = flag.String("rom", "", "must specify a .gb or .gbc rom")
+var rom *string = flag.String("rom", "", "must specify a .gb or .gbc rom, optionally inside a .zip or .gz archive")
 var bootrom *string = flag.String("bootrom", "", "optionally specify a boot rom to play")
 var debugMode *bool = flag.Bool("d", false, "optionally enable debug mode")
 var stats *bool = flag.Bool("stats", false, "optionally enable fps and emu speed tracking")
+var noLocks *bool = flag.Bool("nolocks", false, "optionally let the CPU access VRAM/OAM in any PPU mode")
+var lockLog *bool = flag.Bool("locklog", false, "optionally log CPU accesses to VRAM/OAM blocked by the PPU")
+var record *string = flag.String("record", "", "optionally record the joypad input to a movie `file`")
+var patches patchList
+var play *string = flag.String("play", "", "optionally play back the joypad input from a movie `file`")
+var dat *string = flag.String("dat", "", "optionally identify the rom against a No-Intro DAT `file`")
+
+// patchList collects every -patch flag, so patches can be stacked
+type patchList []string
+
+func (p *patchList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patchList) Set(filename string) error {
+	*p = append(*p, filename)
+	return nil
+}
 
 func main() {
 	parseArgs()
 
-	gameboy := gb.NewGameboy(gb.GameboyOptions{
+	gameboy, err := gb.NewGameboy(gb.GameboyOptions{
 		Filename:        *rom,
+		Patches:         patches,
+		DatFilename:     *dat,
 		DebugMode:       *debugMode,
 		BootRomFilename: *bootrom,
 		Stats:           *stats,
+		NoAccessLocks:   *noLocks,
+		LogAccessLocks:  *lockLog,
+		RecordMovie:     *record,
+		PlayMovie:       *play,
 	})
-	gameboy.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Start only returns once the save has been flushed, so exiting on an error loses nothing
+	if err := gameboy.Start(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func parseArgs() {
+	flag.Var(&patches, "patch", "optionally apply an .ips, .ups or .bps patch `file` to the rom, can be repeated to stack patches")
 	flag.Parse()
 
 	if *cpuprofile != "" {
@@ -62,4 +95,10 @@ func parseArgs() {
 		flag.Usage()
 		os.Exit(2)
 	}
+
+	if *record != "" && *play != "" {
+		fmt.Println("Cannot record and play a movie at the same time")
+		flag.Usage()
+		os.Exit(2)
+	}
 }
@@ -2,7 +2,6 @@ package gb
 
 import (
 	"fmt"
-	"log"
 	"os"
 )
 
@@ -19,16 +18,20 @@ const (
 	BOOT_ROM_ENABLE_ADDR = 0xFF50
 )
 
-func newBootROM(filename string, mmu *MMU) *BootRom {
+func newBootROM(filename string, mmu *MMU) (*BootRom, error) {
 	b := &BootRom{enableReg: 0x0, mmu: mmu}
 
 	var err error
 	b.rom, err = os.ReadFile(filename)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return b
+	if len(b.rom) <= BOOR_ROM_TOP {
+		return nil, fmt.Errorf("boot rom %s is %d bytes, expected %d", filename, len(b.rom), BOOR_ROM_TOP+1)
+	}
+
+	return b, nil
 }
 
 func (b *BootRom) contains(addr uint16) bool {
@@ -1,8 +1,9 @@
 package gb
 
 import (
+	"bytes"
 	"fmt"
-	"log"
+	"hash/crc32"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,6 +18,9 @@ type Cart struct {
 	romSize     uint32
 	ramSize     uint32
 	title       string
+	name        string // identifies the ROM for save and cheat files, patched ROMs get their own
+	dump        []byte // the ROM as read from disk, before any patches
+	patched     bool
 	savFilePath string
 
 	mbc      MemoryBankController
@@ -24,11 +28,15 @@ type Cart struct {
 
 	hasRam  bool
 	battery bool
+
+	romPatches map[uint16][]*GameGenieCode // enabled Game Genie codes by address
 }
 
 type MemoryBankController interface {
 	Addressable
 	init(cart *Cart)
+	romBank(addr uint16) uint32
+	ramBank() uint32
 }
 
 const (
@@ -37,6 +45,12 @@ const (
 
 	EXT_RAM_BASE = 0xA000
 	EXT_RAM_TOP  = 0xBFFF
+
+	ROM_BANK_SIZE = 0x4000
+	RAM_BANK_SIZE = 0x2000
+
+	// the bus maps two ROM banks, so no cart has less than this
+	MIN_ROM_SIZE = 2 * ROM_BANK_SIZE
 )
 
 var cartTypes = map[int]string{
@@ -84,15 +98,37 @@ var ramSizes = map[uint8]uint32{
 	0x05: 0x10000, // 65536   bytes
 }
 
-func (c *Cart) load(filename string) {
-	rom, err := os.ReadFile(filename)
+// load reads the ROM and applies each patch in order. Without explicit patches, an .ips, .ups or .bps file
+// with the same base name as the ROM is applied
+func (c *Cart) load(filename string, patches []string) error {
+	rom, romPath, err := readROM(filename)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	c.name = strings.TrimSuffix(filepath.Base(romPath), filepath.Ext(romPath))
+
+	if len(patches) == 0 {
+		patches = findPatch(romPath)
+	}
+
+	c.dump = rom
+	for _, patch := range patches {
+		if rom, err = loadPatch(rom, patch); err != nil {
+			return fmt.Errorf("could not apply patch %s: %w", patch, err)
+		}
+		fmt.Printf("Applied patch %s\n", patch)
+	}
+
+	c.patched = len(patches) != 0
+	if c.patched {
+		// keep saves of patched games, e.g. translations, apart from the original
+		c.name = fmt.Sprintf("%s-%08X", c.name, crc32.ChecksumIEEE(rom))
 	}
 
 	c.rom = rom
 	if len(c.rom) < 0x150 {
-		log.Fatal("Invalid gameboy cartridge")
+		return fmt.Errorf("%s is not a gameboy cartridge, it is too small to hold a header", filename)
 	}
 
 	c.title = strings.ReplaceAll(string(c.rom[0x0134:0x013F]), "\x00", "")
@@ -101,7 +137,10 @@ func (c *Cart) load(filename string) {
 	}
 	c.cartType = c.rom[0x0147]
 	c.battery = strings.Contains(strings.ToLower(cartTypes[int(c.cartType)]), "battery")
-	c.romSize = 32 * (1 << c.rom[0x0148]) * 1024
+	if code := c.rom[ROM_SIZE_CODE]; code > MAX_ROM_SIZE_CODE {
+		return fmt.Errorf("%s has an unknown ROM size code 0x%02X", filename, code)
+	}
+	c.romSize = ROM_SIZE_UNIT << c.rom[ROM_SIZE_CODE]
 	c.ramSize = ramSizes[c.rom[0x0149]]
 	c.hasRam = c.ramSize != 0
 
@@ -110,54 +149,116 @@ func (c *Cart) load(filename string) {
 		c.mbc = &MBC1{}
 	} else if c.cartType >= 0x0F && c.cartType <= 0x13 {
 		c.mbc = &MBC3{}
+	} else if !c.romOnly() {
+		return fmt.Errorf("unsupported cartridge type 0x%02X (%s)", c.cartType, cartTypes[int(c.cartType)])
 	}
 
 	if c.battery {
-		c.ram = c.loadSave(filename)
+		if c.ram, err = c.loadSave(); err != nil {
+			return fmt.Errorf("could not load save: %w", err)
+		}
 	}
 
 	c.printHeader()
+	for _, warning := range c.validateHeader() {
+		fmt.Println("WARNING:", warning)
+	}
+
+	// the cart is run at the size the header declares, banks missing from a short dump read as open bus
+	if size := max(int(c.romSize), MIN_ROM_SIZE); len(c.rom) < size {
+		c.rom = append(c.rom, bytes.Repeat([]byte{0xFF}, size-len(c.rom))...)
+	} else {
+		c.rom = c.rom[:size]
+	}
+
 	if !c.romOnly() {
 		c.mbc.init(c)
 	}
+
+	return nil
 }
 
-func (c *Cart) loadSave(filename string) mmap.MMap {
+func (c *Cart) loadSave() (mmap.MMap, error) {
 	savDir := "saves"
 	if err := os.MkdirAll(savDir, os.ModePerm); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	savFilename := fmt.Sprintf("%s.sav", strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
-	c.savFilePath = filepath.Join(savDir, savFilename)
+	savFilePath := filepath.Join(savDir, c.name+".sav")
 
-	sav, err := os.OpenFile(c.savFilePath, os.O_CREATE|os.O_RDWR, 0644)
+	sav, err := os.OpenFile(savFilePath, os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	defer sav.Close()
 
 	if err := sav.Truncate(int64(c.ramSize)); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	sram, err := mmap.Map(sav, mmap.RDWR, 0)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return sram
+	// only set once mapped, so syncSave never touches a save that failed to load
+	c.savFilePath = savFilePath
+	return sram, nil
 }
 
-func (c *Cart) syncSave() {
-	if !c.battery {
-		return
+// replaceRAM swaps the cart RAM for an in-memory copy of sram, so nothing is written back to the save file
+func (c *Cart) replaceRAM(sram []byte) error {
+	if c.savFilePath != "" {
+		if err := c.ram.Unmap(); err != nil {
+			return fmt.Errorf("could not unmap save %s: %w", c.savFilePath, err)
+		}
+		c.savFilePath = ""
+	}
+
+	c.ram = make([]byte, c.ramSize)
+	copy(c.ram, sram)
+	return nil
+}
+
+// findPatch looks for a patch next to the ROM with the same base name
+func findPatch(filename string) []string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	for _, ext := range patchExts {
+		if _, err := os.Stat(base + ext); err == nil {
+			return []string{base + ext}
+		}
+	}
+
+	return nil
+}
+
+func loadPatch(rom []byte, filename string) ([]byte, error) {
+	patch, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyPatch(rom, patch)
+}
+
+func (c *Cart) syncSave() error {
+	if !c.battery || c.savFilePath == "" {
+		return nil
+	}
+
+	if err := c.ram.Flush(); err != nil {
+		return fmt.Errorf("could not flush save to %s: %w", c.savFilePath, err)
+	}
+
+	if err := c.ram.Unmap(); err != nil {
+		return err
 	}
 
-	c.ram.Flush()
-	c.ram.Unmap()
 	fmt.Printf("Flushed save to %s\n", c.savFilePath)
+	c.savFilePath = ""
+	return nil
 }
 
 func (c *Cart) printHeader() {
@@ -179,15 +280,32 @@ func (c *Cart) printHeader() {
 }
 
 func (c *Cart) contains(addr uint16) bool {
-	if c.romOnly() {
-		return inRange(addr, ROM_BASE, ROM_TOP)
+	return inRange(addr, ROM_BASE, ROM_TOP) || inRange(addr, EXT_RAM_BASE, EXT_RAM_TOP)
+}
+
+func (c *Cart) read(addr uint16) uint8 {
+	data := c.readMapped(addr)
+
+	if len(c.romPatches) != 0 && addr <= ROM_TOP {
+		for _, gg := range c.romPatches[addr] {
+			data = gg.patch(data)
+		}
 	}
 
-	return c.mbc.contains(addr)
+	return data
 }
 
-func (c *Cart) read(addr uint16) uint8 {
+func (c *Cart) readMapped(addr uint16) uint8 {
 	if c.romOnly() {
+		if inRange(addr, EXT_RAM_BASE, EXT_RAM_TOP) {
+			// nothing drives the bus without cart RAM
+			if !c.hasRam {
+				return 0xFF
+			}
+
+			return c.ram[uint32(addr-EXT_RAM_BASE)%c.ramSize]
+		}
+
 		return c.rom[addr]
 	}
 
@@ -196,12 +314,45 @@ func (c *Cart) read(addr uint16) uint8 {
 
 func (c *Cart) write(addr uint16, data uint8) {
 	if c.romOnly() {
+		if inRange(addr, EXT_RAM_BASE, EXT_RAM_TOP) && c.hasRam {
+			c.ram[uint32(addr-EXT_RAM_BASE)%c.ramSize] = data
+		}
+
 		return
 	}
 
 	c.mbc.write(addr, data)
 }
 
+// cgbSupport reports whether the header declares the game works on, or needs, a CGB
+func (c *Cart) cgbSupport() bool {
+	return bits.IsSet(c.rom[0x0143], 7)
+}
+
 func (c *Cart) romOnly() bool {
-	return c.cartType == 0x00
+	return c.cartType == 0x00 || c.cartType == 0x08 || c.cartType == 0x09
+}
+
+func (c *Cart) romBank(addr uint16) uint32 {
+	if c.romOnly() {
+		return uint32(addr / ROM_BANK_SIZE)
+	}
+
+	return c.mbc.romBank(addr)
+}
+
+func (c *Cart) ramBank() uint32 {
+	if c.romOnly() {
+		return 0
+	}
+
+	return c.mbc.ramBank()
+}
+
+func (c *Cart) numROMBanks() int {
+	return (len(c.rom) + ROM_BANK_SIZE - 1) / ROM_BANK_SIZE
+}
+
+func (c *Cart) numRAMBanks() int {
+	return (len(c.ram) + RAM_BANK_SIZE - 1) / RAM_BANK_SIZE
 }
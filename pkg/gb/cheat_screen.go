@@ -0,0 +1,104 @@
+package gb
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// CheatScreen is an overlay on top of the game screen for turning cheats on and off, emulation is paused while it is open
+type CheatScreen struct {
+	cheats *Cheats
+	buffer *ebiten.Image
+
+	open   bool
+	cursor int
+	scroll int
+}
+
+const CHEAT_CODE_COLS = 12
+
+func newCheatScreen(cheats *Cheats) *CheatScreen {
+	return &CheatScreen{
+		cheats: cheats,
+		buffer: ebiten.NewImage(REBIND_SCREEN_WIDTH, REBIND_SCREEN_HEIGHT),
+	}
+}
+
+func (cs *CheatScreen) show() {
+	cs.open = true
+	cs.cursor = min(cs.cursor, max(len(cs.cheats.cheats)-1, 0))
+}
+
+func (cs *CheatScreen) close() {
+	cs.open = false
+	cs.cheats.save()
+}
+
+func (cs *CheatScreen) handleInput() {
+	n := len(cs.cheats.cheats)
+
+	switch {
+	case keyRepeated(ebiten.KeyArrowUp) && n > 0:
+		cs.cursor = (cs.cursor + n - 1) % n
+	case keyRepeated(ebiten.KeyArrowDown) && n > 0:
+		cs.cursor = (cs.cursor + 1) % n
+	case (inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeySpace)) && n > 0:
+		cs.cheats.toggle(cs.cheats.cheats[cs.cursor])
+	case inpututil.IsKeyJustPressed(ebiten.KeyR):
+		// pick up codes added to the file while running
+		if err := cs.cheats.reload(); err != nil {
+			fmt.Println(err)
+		}
+		cs.cursor = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		cs.close()
+	}
+}
+
+func (cs *CheatScreen) draw(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	cs.buffer.Fill(dbgBackground)
+	dbgPrint(cs.buffer, "CHEATS", 0, 0)
+
+	if len(cs.cheats.cheats) == 0 {
+		dbgPrint(cs.buffer, "No cheats, add them to", 0, 1)
+		dbgPrint(cs.buffer, cs.cheats.path, 0, 2)
+	}
+
+	cs.scroll = min(cs.scroll, cs.cursor)
+	cs.scroll = max(cs.scroll, cs.cursor-REBIND_VISIBLE_ROWS+1)
+
+	for i, c := range cs.cheats.cheats {
+		if i < cs.scroll || i >= cs.scroll+REBIND_VISIBLE_ROWS {
+			continue
+		}
+		row := i - cs.scroll + 1
+
+		if i == cs.cursor {
+			dbgFillCell(cs.buffer, 0, row, REBIND_SCREEN_COLS, dbgCursorColor)
+		}
+
+		state := "[ ]"
+		if c.shark == nil && c.genie == nil {
+			state = "[!]"
+		} else if c.Enabled {
+			state = "[x]"
+		}
+
+		line := fmt.Sprintf("%s %-*s %s", state, CHEAT_CODE_COLS, c.Code, c.Name)
+		if len(line) > REBIND_SCREEN_COLS {
+			line = line[:REBIND_SCREEN_COLS-2] + ".."
+		}
+		dbgPrint(cs.buffer, line, 0, row)
+	}
+
+	help := REBIND_VISIBLE_ROWS + 2
+	dbgPrint(cs.buffer, "Enter: toggle  R: reload file", 0, help)
+	dbgPrint(cs.buffer, "Esc: save and close", 0, help+1)
+
+	bufferOpt := ebiten.DrawImageOptions{}
+	bufferOpt.GeoM.Scale(1.0/REBIND_SCREEN_SCALE, 1.0/REBIND_SCREEN_SCALE)
+	bufferOpt.GeoM.Concat(opt.GeoM)
+	screen.DrawImage(cs.buffer, &bufferOpt)
+}
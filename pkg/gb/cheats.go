@@ -0,0 +1,303 @@
+package gb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Cheats holds the codes for the loaded ROM. GameShark codes write to RAM every VBlank,
+// Game Genie codes patch bytes read from ROM
+type Cheats struct {
+	path    string
+	mmu     *MMU
+	cart    *Cart
+	cheats  []*Cheat
+	locked  bool // set while a movie runs, since the movie only plays back with the cheats it was recorded with
+	damaged bool // the file could not be read in full, so saving over it would lose cheats
+}
+
+// Cheat is one entry of the cheats file, e.g. {"name": "Infinite money", "code": "019947D3", "enabled": true}
+type Cheat struct {
+	Name    string `json:"name"`
+	Code    string `json:"code"`
+	Enabled bool   `json:"enabled"`
+
+	shark *GameSharkCode
+	genie *GameGenieCode
+}
+
+// GameSharkCode is written as ttvvaaaa: the RAM bank type, the value and a little endian address
+type GameSharkCode struct {
+	bank  uint8
+	value uint8
+	addr  uint16
+}
+
+// GameGenieCode is written as vva-aaa-ccc, replacing the ROM byte at addr with value.
+// Codes with a compare value only apply while the byte read from ROM matches it, which picks out one ROM bank
+type GameGenieCode struct {
+	value      uint8
+	addr       uint16
+	compare    uint8
+	hasCompare bool
+}
+
+const (
+	CHEATS_DIR = "cheats"
+
+	GAMESHARK_CODE_LEN     = 8
+	GAMESHARK_EXT_RAM_BANK = 0x80 // 0x8X writes to bank X of the cart RAM, anything else (usually 0x01) goes through the bus
+
+	GAME_GENIE_SHORT_LEN = 6
+	GAME_GENIE_LONG_LEN  = 9
+)
+
+var errCheatsLocked = errors.New("cheats can't be changed while a movie is recording or playing")
+
+// loadCheats never fails, a cheats file that can't be read is reported and the game runs without the cheats in it
+func loadCheats(mmu *MMU, cart *Cart) *Cheats {
+	cs := &Cheats{
+		path: filepath.Join(CHEATS_DIR, cart.name+".json"),
+		mmu:  mmu,
+		cart: cart,
+	}
+
+	if err := cs.reload(); err != nil {
+		fmt.Println("WARNING:", err)
+	}
+
+	return cs
+}
+
+// reload reads the cheats file again, a missing file just means no cheats
+func (cs *Cheats) reload() error {
+	if cs.locked {
+		return errCheatsLocked
+	}
+
+	cs.cheats = nil
+	cs.damaged = false
+
+	data, err := os.ReadFile(cs.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		cs.updatePatches()
+		return nil
+	} else if err != nil {
+		cs.damaged = true
+		cs.updatePatches()
+		return err
+	}
+
+	// entries are decoded one at a time so that a bad one only loses that cheat
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		cs.damaged = true
+		cs.updatePatches()
+		return fmt.Errorf("could not parse cheats file %s, no cheats loaded: %w", cs.path, err)
+	}
+
+	for i, entry := range entries {
+		c := &Cheat{}
+		if err := json.Unmarshal(entry, c); err != nil {
+			fmt.Printf("Skipping cheat %d of %s: %v\n", i+1, cs.path, err)
+			cs.damaged = true
+			continue
+		}
+
+		if err := c.parse(); err != nil {
+			fmt.Printf("Disabling cheat %q: %v\n", c.Name, err)
+			c.Enabled = false
+		}
+		cs.cheats = append(cs.cheats, c)
+	}
+
+	cs.updatePatches()
+	fmt.Printf("Loaded %d cheat(s) from %s\n", len(cs.cheats), cs.path)
+	return nil
+}
+
+func (cs *Cheats) save() {
+	if len(cs.cheats) == 0 {
+		return
+	}
+
+	if cs.damaged {
+		fmt.Printf("Not saving cheats, %s could not be read in full. Fix it and reload with R\n", cs.path)
+		return
+	}
+
+	data, err := json.MarshalIndent(cs.cheats, "", "  ")
+	if err != nil {
+		fmt.Println("Could not save cheats:", err)
+		return
+	}
+
+	if err := os.MkdirAll(CHEATS_DIR, os.ModePerm); err != nil {
+		fmt.Println("Could not save cheats:", err)
+		return
+	}
+
+	if err := os.WriteFile(cs.path, data, 0644); err != nil {
+		fmt.Println("Could not save cheats:", err)
+	}
+}
+
+func (cs *Cheats) add(c *Cheat) {
+	if cs.locked {
+		fmt.Printf("Not adding cheat %q: %v\n", c.Name, errCheatsLocked)
+		return
+	}
+
+	if err := c.parse(); err != nil {
+		fmt.Printf("Not adding cheat %q: %v\n", c.Name, err)
+		return
+	}
+
+	cs.cheats = append(cs.cheats, c)
+	cs.updatePatches()
+}
+
+func (cs *Cheats) toggle(c *Cheat) {
+	if cs.locked {
+		fmt.Println(errCheatsLocked)
+		return
+	}
+
+	if c.shark == nil && c.genie == nil {
+		// the code did not parse
+		return
+	}
+
+	c.Enabled = !c.Enabled
+	cs.updatePatches()
+}
+
+// enabledCodes lists the codes in effect, in the order they are applied
+func (cs *Cheats) enabledCodes() []string {
+	var codes []string
+	for _, c := range cs.cheats {
+		if c.Enabled && (c.shark != nil || c.genie != nil) {
+			codes = append(codes, normalizeCode(c.Code))
+		}
+	}
+
+	return codes
+}
+
+// updatePatches hands the enabled Game Genie codes to the cart, keyed by address
+func (cs *Cheats) updatePatches() {
+	patches := make(map[uint16][]*GameGenieCode)
+	for _, c := range cs.cheats {
+		if c.Enabled && c.genie != nil {
+			patches[c.genie.addr] = append(patches[c.genie.addr], c.genie)
+		}
+	}
+
+	cs.cart.romPatches = patches
+}
+
+// applyRAMCodes is called at the start of every VBlank
+func (cs *Cheats) applyRAMCodes() {
+	for _, c := range cs.cheats {
+		if c.Enabled && c.shark != nil {
+			c.shark.apply(cs.mmu, cs.cart)
+		}
+	}
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func (c *Cheat) parse() error {
+	code := normalizeCode(c.Code)
+
+	var err error
+	switch len(code) {
+	case GAMESHARK_CODE_LEN:
+		c.shark, err = parseGameShark(code)
+	case GAME_GENIE_SHORT_LEN, GAME_GENIE_LONG_LEN:
+		c.genie, err = parseGameGenie(code)
+	default:
+		err = fmt.Errorf("%q is neither a GameShark nor a Game Genie code", c.Code)
+	}
+
+	return err
+}
+
+func parseGameShark(code string) (*GameSharkCode, error) {
+	raw, err := strconv.ParseUint(code, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GameShark code %q", code)
+	}
+
+	gs := &GameSharkCode{
+		bank:  uint8(raw >> 24),
+		value: uint8(raw >> 16),
+		addr:  uint16(raw>>8)&0xFF | uint16(raw&0xFF)<<8,
+	}
+
+	// the write is repeated every frame, so anything outside of RAM would keep hitting MBC or I/O registers
+	if !inRange(gs.addr, EXT_RAM_BASE, WRAM_TOP) && !inRange(gs.addr, HRAM_BASE, HRAM_TOP) {
+		return nil, fmt.Errorf("GameShark code %q does not write RAM (0x%04x)", code, gs.addr)
+	}
+
+	return gs, nil
+}
+
+func (gs *GameSharkCode) apply(mmu *MMU, cart *Cart) {
+	if gs.bank&0xF0 == GAMESHARK_EXT_RAM_BANK && inRange(gs.addr, EXT_RAM_BASE, EXT_RAM_TOP) {
+		// write straight into the bank, no matter which one is mapped or if RAM is enabled
+		if cart.hasRam {
+			offset := uint32(gs.bank&0x0F)*RAM_BANK_SIZE + uint32(gs.addr-EXT_RAM_BASE)
+			cart.ram[offset%cart.ramSize] = gs.value
+		}
+		return
+	}
+
+	mmu.write(gs.addr, gs.value)
+}
+
+// parseGameGenie decodes a code (without dashes) written as VVAAAA or VVAAAACCC
+func parseGameGenie(code string) (*GameGenieCode, error) {
+	nibbles := make([]uint8, len(code))
+	for i, ch := range code {
+		n, err := strconv.ParseUint(string(ch), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Game Genie code %q", code)
+		}
+		nibbles[i] = uint8(n)
+	}
+
+	gg := &GameGenieCode{
+		value: nibbles[0]<<4 | nibbles[1],
+		addr:  uint16(nibbles[5]^0xF)<<12 | uint16(nibbles[2])<<8 | uint16(nibbles[3])<<4 | uint16(nibbles[4]),
+	}
+
+	if gg.addr > ROM_TOP {
+		return nil, fmt.Errorf("Game Genie code %q does not patch ROM (0x%04x)", code, gg.addr)
+	}
+
+	if len(nibbles) == GAME_GENIE_LONG_LEN {
+		// the 8th digit is unused, the other two are the compare value rotated and scrambled
+		cmp := nibbles[6]<<4 | nibbles[8]
+		gg.compare = (cmp>>2 | cmp<<6) ^ 0xBA
+		gg.hasCompare = true
+	}
+
+	return gg, nil
+}
+
+func (gg *GameGenieCode) patch(data uint8) uint8 {
+	if gg.hasCompare && data != gg.compare {
+		return data
+	}
+
+	return gg.value
+}
@@ -0,0 +1,157 @@
+package gb
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseGameShark(t *testing.T) {
+	tests := []struct {
+		code string
+		want GameSharkCode
+	}{
+		{"01FF47D3", GameSharkCode{bank: 0x01, value: 0xFF, addr: 0xD347}},
+		{"01ff-47d3", GameSharkCode{bank: 0x01, value: 0xFF, addr: 0xD347}},
+		{"9163 00A0", GameSharkCode{bank: 0x91, value: 0x63, addr: 0xA000}},
+	}
+
+	for _, tt := range tests {
+		c := &Cheat{Code: tt.code}
+		if err := c.parse(); err != nil {
+			t.Errorf("%s: %v", tt.code, err)
+			continue
+		}
+
+		if c.shark == nil || *c.shark != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.code, c.shark, tt.want)
+		}
+	}
+}
+
+// The compare values are worked out by hand from the Pan Docs description: digits 7 and 9 form a byte
+// that is rotated right by two and XORed with 0xBA
+func TestParseGameGenie(t *testing.T) {
+	tests := []struct {
+		code string
+		want GameGenieCode
+	}{
+		{"3E1-2AF", GameGenieCode{value: 0x3E, addr: 0x012A}},
+		{"3E1-2AF-6EA", GameGenieCode{value: 0x3E, addr: 0x012A, compare: 0x20, hasCompare: true}},
+		{"fff-ffe-eee", GameGenieCode{value: 0xFF, addr: 0x1FFF, compare: 0x01, hasCompare: true}},
+		{"00A-17B-C49", GameGenieCode{value: 0x00, addr: 0x4A17, compare: 0xC8, hasCompare: true}},
+	}
+
+	for _, tt := range tests {
+		c := &Cheat{Code: tt.code}
+		if err := c.parse(); err != nil {
+			t.Errorf("%s: %v", tt.code, err)
+			continue
+		}
+
+		if c.genie == nil || *c.genie != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.code, c.genie, tt.want)
+		}
+	}
+}
+
+func TestGameGeniePatch(t *testing.T) {
+	always := &GameGenieCode{value: 0x3E}
+	compared := &GameGenieCode{value: 0x3E, compare: 0x20, hasCompare: true}
+
+	tests := []struct {
+		gg   *GameGenieCode
+		data uint8
+		want uint8
+	}{
+		{always, 0x00, 0x3E},
+		{always, 0x20, 0x3E},
+		{compared, 0x20, 0x3E},
+		{compared, 0x21, 0x21},
+	}
+
+	for _, tt := range tests {
+		if got := tt.gg.patch(tt.data); got != tt.want {
+			t.Errorf("%+v patching 0x%02X = 0x%02X, want 0x%02X", *tt.gg, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalidCheats(t *testing.T) {
+	codes := []string{
+		"",
+		"0",
+		"01FF47D",
+		"01FF47D3A",
+		"GGGGGGGG",
+		"+1FF47D3",
+		"0x1F47D3",
+		"01_F47D3",
+		"3E1-2AG",
+		"3E1-2AF-6EZ",
+		"ééAB",
+		"ééééAB",
+		"3E1-2A7",  // 0x812A is VRAM, not ROM
+		"01FF0020", // MBC register
+		"01FF0080", // VRAM
+		"01FF00E0", // echo RAM
+		"01FF40FF", // I/O register
+		"01FFFFFF", // IE
+	}
+
+	for _, code := range codes {
+		c := &Cheat{Code: code}
+		if err := c.parse(); err == nil {
+			t.Errorf("%q parsed as %+v %+v", code, c.shark, c.genie)
+		}
+	}
+}
+
+func TestLoadDamagedCheats(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		codes   []string
+		parsed  bool
+		damaged bool
+	}{
+		{"valid", `[{"name": "a", "code": "01FF47D3", "enabled": true}, {"name": "b", "code": "3E1-2AF"}]`, []string{"01FF47D3"}, true, false},
+		{"bad code", `[{"name": "a", "code": "01FF0020", "enabled": true}, {"name": "b", "code": "3E1-2AF", "enabled": true}]`, []string{"3E12AF"}, true, false},
+		{"bad entry", `[{"name": 1, "code": "01FF47D3"}, {"name": "b", "code": "3E1-2AF", "enabled": true}]`, []string{"3E12AF"}, true, true},
+		{"not JSON", `[{"name": "a", "code": "01FF47D3", "enabled": true},`, nil, false, true},
+		{"not a list", `{"name": "a", "code": "01FF47D3", "enabled": true}`, nil, false, true},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, "cheats.json")
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cs := &Cheats{path: path, cart: &Cart{}}
+		if err := cs.reload(); (err == nil) != tt.parsed {
+			t.Errorf("%s: reload returned %v", tt.name, err)
+		}
+
+		if codes := cs.enabledCodes(); !slices.Equal(codes, tt.codes) {
+			t.Errorf("%s: enabled codes %q, want %q", tt.name, codes, tt.codes)
+		}
+
+		if cs.damaged != tt.damaged {
+			t.Errorf("%s: damaged is %v, want %v", tt.name, cs.damaged, tt.damaged)
+		}
+
+		if !cs.damaged {
+			continue
+		}
+
+		// saving would drop the cheats that could not be read
+		cs.add(&Cheat{Name: "c", Code: "01FFA0C0"})
+		cs.save()
+		if data, err := os.ReadFile(path); err != nil || string(data) != tt.data {
+			t.Errorf("%s: cheats file was overwritten with %q", tt.name, data)
+		}
+	}
+}
@@ -1,13 +1,25 @@
 package gb
 
-import "github.com/BeralaWoolies/GameboyGo/pkg/bits"
+import (
+	"fmt"
+
+	"github.com/BeralaWoolies/GameboyGo/pkg/bits"
+)
 
 type CPU struct {
 	reg            *Registers
 	mmu            *MMU
+	dmac           *DMAController
 	cbInstructions [0x100]func()
+	tick           func(cTicks int) // advances the rest of the system, called once per M-cycle
 	ticks          int
+	instrTicks     int // ticks spent on the bus so far by the current instruction
 	halted         bool
+	haltBug        bool // next opcode fetch does not increment PC
+	stopped        bool
+	locked         bool   // hung by an illegal opcode until the emulator is restarted
+	lockedPC       uint16 // address of the illegal opcode
+	onStop         func() // lets the rest of the system enter low-power mode on STOP
 	IME            bool
 	IMEDelay       bool
 }
@@ -32,19 +44,56 @@ const (
 	CARRY_FLAG_BIT      = 4
 )
 
-func (cpu *CPU) init(mmu *MMU) {
+// CPULockupError is reported once the CPU has hung on an illegal opcode
+type CPULockupError struct {
+	Bank   uint32
+	PC     uint16
+	Opcode uint8
+}
+
+const M_CYCLE_TICKS = 4
+
+func (e *CPULockupError) Error() string {
+	return fmt.Sprintf("CPU locked at %02X:%04X (illegal opcode 0x%02X), restart the emulator to reset it", e.Bank, e.PC, e.Opcode)
+}
+
+func (cpu *CPU) init(mmu *MMU, dmac *DMAController, tick func(cTicks int)) {
 	cpu.reg = &Registers{}
 	cpu.mmu = mmu
+	cpu.dmac = dmac
+	cpu.tick = tick
 	cpu.cbInstructions = cpu.initCbInstructions()
 	cpu.ticks = 0
 	cpu.halted = false
+	cpu.haltBug = false
+	cpu.stopped = false
+	cpu.locked = false
 	cpu.IME = false
 	cpu.IMEDelay = false
 }
 
+// step executes one instruction, the rest of the system is ticked as the instruction goes
+// so every memory access lands on the M-cycle it would on hardware
 func (cpu *CPU) step() int {
+	cpu.instrTicks = 0
+
+	// EI takes effect once the instruction after it has started, so interrupts are
+	// only dispatched after that instruction completes
+	if cpu.IMEDelay {
+		cpu.clearIMEDelay()
+		cpu.setIME()
+	}
+
 	opcode := cpu.nextPC()
-	return cpu.executeInstr(opcode)
+	ticks := cpu.executeInstr(opcode)
+
+	// internal M-cycles that come after the last memory access are not modelled by the
+	// instruction itself, so pad out the rest of the instruction from the timing table
+	for cpu.instrTicks < ticks {
+		cpu.internalDelay()
+	}
+
+	return cpu.instrTicks
 }
 
 func (cpu *CPU) executeInstr(opcode uint8) int {
@@ -53,23 +102,55 @@ func (cpu *CPU) executeInstr(opcode uint8) int {
 	return instrBaseTicks[opcode] + branchTicks
 }
 
+func (cpu *CPU) read(addr uint16) uint8 {
+	cpu.cycle()
+
+	if cpu.dmac.busConflict(addr) {
+		return cpu.dmac.busByte
+	}
+
+	return cpu.mmu.read(addr)
+}
+
+func (cpu *CPU) write(addr uint16, data uint8) {
+	cpu.cycle()
+	cpu.mmu.write(addr, data)
+}
+
+// internalDelay spends an M-cycle without touching the bus
+func (cpu *CPU) internalDelay() {
+	cpu.cycle()
+}
+
+func (cpu *CPU) cycle() {
+	cpu.instrTicks += M_CYCLE_TICKS
+	cpu.tick(M_CYCLE_TICKS)
+}
+
 func (cpu *CPU) pushStack(addr uint16) {
-	cpu.mmu.write(cpu.reg.SP-1, bits.HiByte(addr))
-	cpu.mmu.write(cpu.reg.SP-2, bits.LoByte(addr))
+	// SP is decremented on an M-cycle of its own before the writes
+	cpu.internalDelay()
+	cpu.write(cpu.reg.SP-1, bits.HiByte(addr))
+	cpu.write(cpu.reg.SP-2, bits.LoByte(addr))
 
 	cpu.setSP(cpu.reg.SP - 2)
 }
 
 func (cpu *CPU) popStack() uint16 {
-	loByte := cpu.mmu.read(cpu.reg.SP)
-	hiByte := cpu.mmu.read(cpu.reg.SP + 1)
+	loByte := cpu.read(cpu.reg.SP)
+	hiByte := cpu.read(cpu.reg.SP + 1)
 
 	cpu.setSP(cpu.reg.SP + 2)
 	return uint16(hiByte)<<8 | uint16(loByte)
 }
 
 func (cpu *CPU) nextPC() uint8 {
-	data := cpu.mmu.read(cpu.reg.PC)
+	data := cpu.read(cpu.reg.PC)
+	if cpu.haltBug {
+		cpu.haltBug = false
+		return data
+	}
+
 	cpu.reg.PC++
 	return data
 }
@@ -215,3 +296,26 @@ func (cpu *CPU) enterHaltedState() {
 func (cpu *CPU) exitHaltedState() {
 	cpu.halted = false
 }
+
+// lockUp hangs the CPU like an illegal opcode does on hardware. There is no reset, so it stays hung until the
+// emulator is restarted
+func (cpu *CPU) lockUp() {
+	cpu.locked = true
+	cpu.lockedPC = cpu.reg.PC - 1
+}
+
+func (cpu *CPU) enterStoppedState() {
+	cpu.stopped = true
+	if cpu.onStop != nil {
+		cpu.onStop()
+	}
+}
+
+func (cpu *CPU) exitStoppedState() {
+	cpu.stopped = false
+}
+
+// intruptPending reports whether any enabled interrupt is requested, regardless of IME
+func (cpu *CPU) intruptPending() bool {
+	return cpu.mmu.read(IE_ADDR)&cpu.mmu.read(IF_ADDR)&^INTRUPT_MSK != 0
+}
@@ -0,0 +1,77 @@
+package gb
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Shared drawing and input helpers for the panels shown in debug mode
+
+const (
+	DBG_CHAR_WIDTH  = 6
+	DBG_CHAR_HEIGHT = 16
+
+	KEY_REPEAT_DELAY    = 20
+	KEY_REPEAT_INTERVAL = 3
+)
+
+var (
+	dbgBackground  = hexToRGBA(0x181818)
+	dbgCursorColor = hexToRGBA(0x3060c0)
+	dbgHighlight   = hexToRGBA(0xc03030)
+	dbgMatchColor  = hexToRGBA(0x308030)
+
+	dbgViewportColor = hexToRGBA(0xff2020)
+	dbgWindowColor   = hexToRGBA(0x2060ff)
+	dbgHoverColor    = hexToRGBA(0xffffff)
+)
+
+func dbgPrint(buffer *ebiten.Image, str string, col int, row int) {
+	ebitenutil.DebugPrintAt(buffer, str, col*DBG_CHAR_WIDTH, row*DBG_CHAR_HEIGHT)
+}
+
+func dbgFillCell(buffer *ebiten.Image, col int, row int, width int, clr color.Color) {
+	vector.DrawFilledRect(buffer, float32(col*DBG_CHAR_WIDTH), float32(row*DBG_CHAR_HEIGHT),
+		float32(width*DBG_CHAR_WIDTH), DBG_CHAR_HEIGHT, clr, false)
+}
+
+// cursorIn reports the cursor position relative to the given panel, and whether it is inside of it
+func cursorIn(x int, y int, width int, height int) (int, int, bool) {
+	cx, cy := ebiten.CursorPosition()
+	cx -= x
+	cy -= y
+
+	return cx, cy, cx >= 0 && cy >= 0 && cx < width && cy < height
+}
+
+func keyRepeated(key ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || (d >= KEY_REPEAT_DELAY && (d-KEY_REPEAT_DELAY)%KEY_REPEAT_INTERVAL == 0)
+}
+
+func fadeColor(clr color.RGBA, alpha uint8) color.RGBA {
+	// colors are premultiplied by alpha in ebiten
+	return color.RGBA{
+		R: uint8(uint16(clr.R) * uint16(alpha) / 0xFF),
+		G: uint8(uint16(clr.G) * uint16(alpha) / 0xFF),
+		B: uint8(uint16(clr.B) * uint16(alpha) / 0xFF),
+		A: alpha,
+	}
+}
+
+func hexDigit(r rune) (uint8, bool) {
+	switch {
+	case r >= '0' && r <= '9':
+		return uint8(r - '0'), true
+	case r >= 'a' && r <= 'f':
+		return uint8(r-'a') + 10, true
+	case r >= 'A' && r <= 'F':
+		return uint8(r-'A') + 10, true
+	default:
+		return 0, false
+	}
+}
@@ -2,36 +2,48 @@ package gb
 
 type DMAController struct {
 	mmu *MMU
+	ppu *PPU
 
 	src      uint8
 	active   bool
 	currByte uint16
-	delayed  bool
+	busByte  uint8 // last byte transferred, which is what the CPU sees on a conflicting read
+
+	// a write to the DMA register only starts the transfer after a 1 M-cycle delay,
+	// a transfer that is already running keeps going until then
+	pending      bool
+	pendingSrc   uint8
+	pendingDelay int
 }
 
-func (dmac *DMAController) init(mmu *MMU) {
+const (
+	DMA_START_DELAY = 1
+	DMA_ECHO_BASE   = 0xE000
+	DMA_ECHO_OFFSET = 0x2000
+)
+
+func (dmac *DMAController) init(mmu *MMU, ppu *PPU) {
 	dmac.src = 0
 	dmac.active = false
 	dmac.currByte = 0
-	dmac.delayed = false
+	dmac.pending = false
 	dmac.mmu = mmu
+	dmac.ppu = ppu
 }
 
 func (dmac *DMAController) step(cTicks int) {
-	if !dmac.active {
-		return
-	}
-
-	if dmac.delayed {
-		cTicks -= 4
-		dmac.delayed = false
-
-		if cTicks <= 0 {
-			return
+	for i := 0; i < cTicks; i += M_CYCLE_TICKS {
+		if dmac.pending {
+			if dmac.pendingDelay == 0 {
+				dmac.pending = false
+				dmac.src = dmac.pendingSrc
+				dmac.currByte = 0
+				dmac.active = true
+			} else {
+				dmac.pendingDelay--
+			}
 		}
-	}
 
-	for i := 0; i < cTicks; i += 4 {
 		dmac.transferOAM()
 	}
 }
@@ -41,17 +53,33 @@ func (dmac *DMAController) transferOAM() {
 		return
 	}
 
-	dest := OAM_BASE + dmac.currByte
-	src := dmac.mmu.read((uint16(dmac.src) * 0x100) + dmac.currByte)
-	dmac.mmu.write(dest, src)
+	// sources from 0xE000 upwards go to WRAM through the echo mapping
+	addr := (uint16(dmac.src) * 0x100) + dmac.currByte
+	if addr >= DMA_ECHO_BASE {
+		addr -= DMA_ECHO_OFFSET
+	}
+
+	// the PPU access locks only apply to the CPU, so VRAM is read directly.
+	// OAM is locked to the CPU for the duration of the transfer, so write it directly too
+	if p := dmac.ppu.memPtr(addr); p != nil {
+		dmac.busByte = *p
+	} else {
+		dmac.busByte = dmac.mmu.read(addr)
+	}
+	dmac.ppu.oam[dmac.currByte] = dmac.busByte
 
 	dmac.currByte++
 	dmac.active = dmac.currByte < OAM_SIZE
 }
 
 func (dmac *DMAController) initOAMTransfer(data uint8) {
-	dmac.src = data
-	dmac.active = true
-	dmac.currByte = 0
-	dmac.delayed = true
+	dmac.pending = true
+	dmac.pendingSrc = data
+	dmac.pendingDelay = DMA_START_DELAY
+}
+
+// busConflict reports whether a CPU read of addr collides with the running transfer,
+// only HRAM and the IO registers stay reachable while DMA owns the bus
+func (dmac *DMAController) busConflict(addr uint16) bool {
+	return dmac.active && addr < JOYP_ADDR
 }
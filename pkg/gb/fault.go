@@ -0,0 +1,57 @@
+package gb
+
+import "fmt"
+
+// BusError is an access the memory map could not route, either because nothing is mapped at the address
+// or because the component it was routed to does not own it. Either way the emulator can't carry on
+type BusError struct {
+	Addr      uint16
+	Write     bool
+	Component string // empty when nothing is mapped at Addr
+}
+
+func (e *BusError) Error() string {
+	access := "read from"
+	if e.Write {
+		access = "write to"
+	}
+
+	if e.Component == "" {
+		return fmt.Sprintf("bus error: %s unmapped address 0x%04x", access, e.Addr)
+	}
+
+	return fmt.Sprintf("bus error: %s 0x%04x was routed to %s, which does not own it", access, e.Addr, e.Component)
+}
+
+// emulatorFault carries an error out of the component that hit it, so that the bus doesn't have to return
+// errors on every access
+type emulatorFault struct {
+	err error
+}
+
+// raiseFault unwinds to the nearest recoverFault. Only raise it from code reached through runFrame, Update or Draw,
+// which recover it, anywhere else it crashes the program like any other panic
+func raiseFault(err error) {
+	panic(emulatorFault{err: err})
+}
+
+func busFault(component string, addr uint16, write bool) {
+	raiseFault(&BusError{Addr: addr, Write: write, Component: component})
+}
+
+// recoverFault turns a fault raised while emulating into the emulator's fault state, which stops the game
+// without taking down the program. Anything else that panics is left alone
+func (gb *Gameboy) recoverFault() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	f, ok := r.(emulatorFault)
+	if !ok {
+		panic(r)
+	}
+
+	gb.fault = f.err
+	gb.crashed = true
+}
@@ -1,13 +1,14 @@
 package gb
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/BeralaWoolies/GameboyGo/pkg/bits"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
 type Gameboy struct {
@@ -18,22 +19,50 @@ type Gameboy struct {
 	serial       *SerialPort
 	timer        *Timer
 	cart         *Cart
+	bootRom      *BootRom
+	key1         *SpeedSwitch // only for carts with CGB support
 	dmac         *DMAController
 	ic           *IntruptController
-	btnMappings  map[ebiten.Key]func(pressed bool)
+	memViewer    *MemoryViewer
+	ramSearch    *RamSearch
+	oamViewer    *OAMViewer
+	tileViewer   *TileDataViewer
+	rasterViewer *RasterViewer
+	input        *InputConfig
+	rebindScreen *RebindScreen
+	cheats       *Cheats
+	cheatScreen  *CheatScreen
+	joypActions  map[InputAction]func(pressed bool)
+	turbo        *Turbo
+	macros       *MacroPlayer
+	movie        *Movie
 	opts         GameboyOptions
 	screenWidth  int
 	screenHeight int
 	windowWidth  int
 	windowHeight int
-	speedUp      bool
+	speed        float64 // throttled emulation speed, 1 is real time
+	fastForward  bool
+	paused       bool
+	advanceFrame bool          // run a single frame while paused
+	frameCost    time.Duration // how long emulating a frame takes on this machine, used to size fast forward batches
+	framesRun    int           // frames emulated by the last Update
+	fault        error
+	crashed      bool // a fault raised while emulating stopped the game for good
+	switchTicks  int  // time left in STOP while a speed switch completes
 }
 
 type GameboyOptions struct {
 	Filename        string
+	Patches         []string // applied to the ROM in order, instead of looking for a patch next to it
+	DatFilename     string   // No-Intro DAT to identify the ROM with
 	DebugMode       bool
 	BootRomFilename string
 	Stats           bool
+	NoAccessLocks   bool // let the CPU access VRAM/OAM in any PPU mode
+	LogAccessLocks  bool // print every CPU access to VRAM/OAM that the PPU blocks
+	RecordMovie     string
+	PlayMovie       string
 }
 
 const (
@@ -48,17 +77,30 @@ const (
 
 	TILE_MAP_SCREEN_WIDTH  = 256
 	TILE_MAP_SCREEN_HEIGHT = 256
+
+	// debug panels sit on a second row underneath the screen, tile data and tile maps
+	GB_SCREEN_DBG_Y      = (TILE_DATA_SCREEN_HEIGHT - GB_SCREEN_HEIGHT) / 2
+	TILE_DATA_DBG_X      = GB_SCREEN_WIDTH
+	TILE_MAPS_DBG_X      = TILE_DATA_DBG_X + TILE_DATA_SCREEN_WIDTH
+	DBG_PANEL_ROW_Y      = max(GB_SCREEN_HEIGHT, TILE_DATA_SCREEN_HEIGHT, TILE_MAP_SCREEN_HEIGHT)
+	DBG_PANEL_ROW_HEIGHT = max(MEM_VIEWER_SCREEN_HEIGHT, OAM_VIEWER_SCREEN_HEIGHT, RASTER_VIEWER_SCREEN_HEIGHT)
+	DBG_PANEL_ROW_WIDTH  = MEM_VIEWER_SCREEN_WIDTH + OAM_VIEWER_SCREEN_WIDTH + RASTER_VIEWER_SCREEN_WIDTH
+	RASTER_VIEWER_DBG_X  = MEM_VIEWER_SCREEN_WIDTH + OAM_VIEWER_SCREEN_WIDTH
+	RAM_SEARCH_DBG_X     = TILE_MAPS_DBG_X + 2*TILE_MAP_SCREEN_WIDTH
 )
 
-func NewGameboy(opts GameboyOptions) *Gameboy {
+func NewGameboy(opts GameboyOptions) (*Gameboy, error) {
 	gb := &Gameboy{opts: opts}
-	gb.init(opts.Filename)
+	if err := gb.init(opts.Filename); err != nil {
+		// let go of the save file if the cart got as far as mapping it
+		return nil, errors.Join(err, gb.cart.syncSave())
+	}
 
 	if gb.opts.DebugMode {
-		gb.screenWidth = GB_SCREEN_WIDTH + TILE_DATA_SCREEN_WIDTH + (2 * TILE_MAP_SCREEN_WIDTH)
-		gb.screenHeight = max(GB_SCREEN_HEIGHT, TILE_DATA_SCREEN_HEIGHT, TILE_MAP_SCREEN_HEIGHT)
-		gb.windowWidth = gb.screenWidth * 3
-		gb.windowHeight = gb.screenHeight * 3
+		gb.screenWidth = max(RAM_SEARCH_DBG_X+RAM_SEARCH_SCREEN_WIDTH, DBG_PANEL_ROW_WIDTH)
+		gb.screenHeight = DBG_PANEL_ROW_Y + DBG_PANEL_ROW_HEIGHT
+		gb.windowWidth = gb.screenWidth * 2
+		gb.windowHeight = gb.screenHeight * 2
 	} else {
 		gb.screenWidth = GB_SCREEN_WIDTH
 		gb.screenHeight = GB_SCREEN_HEIGHT
@@ -66,16 +108,45 @@ func NewGameboy(opts GameboyOptions) *Gameboy {
 		gb.windowHeight = gb.screenHeight * 4
 	}
 
-	return gb
+	return gb, nil
 }
 
-func (gb *Gameboy) init(filename string) {
-	gb.initHardware(filename)
-	gb.initMemoryMap()
-	gb.bindUIEvents()
+func (gb *Gameboy) init(filename string) error {
+	if err := gb.initHardware(filename); err != nil {
+		return err
+	}
+
+	if err := gb.initMemoryMap(); err != nil {
+		return err
+	}
+
+	if err := gb.bindUIEvents(); err != nil {
+		return err
+	}
+
+	gb.cheats = loadCheats(gb.mmu, gb.cart)
+	gb.cheatScreen = newCheatScreen(gb.cheats)
+	gb.ppu.onVBlank = gb.cheats.applyRAMCodes
+
+	var err error
+	if gb.opts.RecordMovie != "" {
+		gb.movie, err = newMovieRecorder(gb.opts.RecordMovie, gb.cart, gb.bootRom, gb.cheats.enabledCodes())
+	} else if gb.opts.PlayMovie != "" {
+		gb.movie, err = newMoviePlayer(gb.opts.PlayMovie, gb.cart, gb.bootRom, gb.cheats.enabledCodes())
+	}
+	if err != nil {
+		return err
+	}
+	gb.cheats.locked = gb.movie != nil
+
+	if gb.opts.DebugMode {
+		gb.initDebugPanels()
+	}
+
+	return nil
 }
 
-func (gb *Gameboy) initHardware(filename string) {
+func (gb *Gameboy) initHardware(filename string) error {
 	gb.mmu = &MMU{}
 	gb.cpu = &CPU{}
 	gb.ppu = &PPU{}
@@ -86,19 +157,46 @@ func (gb *Gameboy) initHardware(filename string) {
 	gb.dmac = &DMAController{}
 	gb.ic = &IntruptController{}
 
-	gb.cpu.init(gb.mmu)
+	gb.cpu.init(gb.mmu, gb.dmac, gb.tickSystem)
+	gb.cpu.onStop = gb.enterStopMode
 	gb.ppu.init(gb.mmu, gb.dmac, gb.ic)
 	gb.joyp.init(gb.ic)
 	gb.serial.init(gb.ic)
 	gb.timer.init(gb.mmu, gb.ic)
-	gb.cart.load(filename)
-	gb.dmac.init(gb.mmu)
+	if err := gb.cart.load(filename, gb.opts.Patches); err != nil {
+		return err
+	}
+	if gb.opts.DatFilename != "" {
+		gb.cart.identify(gb.opts.DatFilename)
+	}
+	gb.dmac.init(gb.mmu, gb.ppu)
 	gb.ic.init(gb.mmu, gb.cpu)
+
+	gb.ppu.accessLocks = !gb.opts.NoAccessLocks
+	if gb.opts.LogAccessLocks {
+		gb.ppu.onBlockedAccess = gb.logBlockedAccess
+	}
+
+	return nil
+}
+
+func (gb *Gameboy) logBlockedAccess(addr uint16, write bool) {
+	access := "read from"
+	if write {
+		access = "write to"
+	}
+
+	fmt.Printf("Blocked %s 0x%04x in PPU mode %d (PC: 0x%04x, LY: %d, DMA active: %t)\n",
+		access, addr, gb.ppu.currState, gb.cpu.reg.PC, gb.ppu.ly, gb.dmac.active)
 }
 
-func (gb *Gameboy) initMemoryMap() {
+func (gb *Gameboy) initMemoryMap() error {
 	if gb.hasBootRom() {
-		gb.mmu.mapAddrSpace(newBootROM(gb.opts.BootRomFilename, gb.mmu))
+		var err error
+		if gb.bootRom, err = newBootROM(gb.opts.BootRomFilename, gb.mmu); err != nil {
+			return err
+		}
+		gb.mmu.mapAddrSpace(gb.bootRom)
 	}
 	gb.mmu.mapAddrSpace(gb.cart)
 	gb.mmu.mapAddrSpace(gb.ppu)
@@ -106,36 +204,57 @@ func (gb *Gameboy) initMemoryMap() {
 	gb.mmu.mapAddrSpace(gb.serial)
 	gb.mmu.mapAddrSpace(gb.timer)
 	gb.mmu.mapAddrSpace(gb.ic)
+	gb.mmu.mapAddrSpace(newWorkRAM())
+	gb.mmu.mapAddrSpace(newUnusableRegion(gb.ppu))
+	gb.mmu.mapAddrSpace(newHighRAM())
+	if gb.cart.cgbSupport() {
+		gb.key1 = &SpeedSwitch{}
+		gb.mmu.mapAddrSpace(gb.key1)
+	}
+
+	// I/O registers that no component owns fall through to here
+	gb.mmu.mapAddrSpace(newIORegisters())
+
+	return nil
+}
 
-	// for now have our generic RAM be last in precedence to "catch" unimplemented addresses
-	gb.mmu.mapAddrSpace(newGenericRAM())
+func (gb *Gameboy) initDebugPanels() {
+	gb.memViewer = newMemoryViewer(gb.mmu, gb.cart, gb.ppu, 0, DBG_PANEL_ROW_Y)
+	gb.mmu.onWrite = gb.memViewer.recordWrite
+	gb.ramSearch = newRamSearch(gb.mmu, gb.cart, gb.cheats, RAM_SEARCH_DBG_X, 0)
+	gb.oamViewer = newOAMViewer(gb.ppu)
+	gb.tileViewer = newTileDataViewer(gb.ppu, TILE_DATA_DBG_X, 0)
+
+	gb.ppu.rasterLog = newRasterLog()
+	gb.rasterViewer = newRasterViewer(gb.ppu.rasterLog, RASTER_VIEWER_DBG_X, DBG_PANEL_ROW_Y)
 }
 
 func (gb *Gameboy) hasBootRom() bool {
 	return gb.opts.BootRomFilename != ""
 }
 
-func (gb *Gameboy) bindUIEvents() {
-	gb.btnMappings = map[ebiten.Key]func(pressed bool){
-		ebiten.KeyArrowUp:    gb.joyp.up.press,
-		ebiten.KeyArrowDown:  gb.joyp.down.press,
-		ebiten.KeyArrowRight: gb.joyp.right.press,
-		ebiten.KeyArrowLeft:  gb.joyp.left.press,
-		ebiten.KeyA:          gb.joyp.a.press,
-		ebiten.KeyS:          gb.joyp.b.press,
-		ebiten.KeySpace:      gb.joyp.sel.press,
-		ebiten.KeyEnter:      gb.joyp.start.press,
+func (gb *Gameboy) bindUIEvents() error {
+	var err error
+	if gb.input, err = loadInputConfig(); err != nil {
+		return err
 	}
-}
 
-func (gb *Gameboy) toggleSpeed() {
-	gb.speedUp = !gb.speedUp
-
-	if gb.speedUp {
-		ebiten.SetTPS(FPS * 2)
-	} else {
-		ebiten.SetTPS(FPS)
+	gb.rebindScreen = newRebindScreen(gb.input)
+	gb.turbo = newTurbo(gb.input.turboRate)
+	gb.macros = &MacroPlayer{}
+
+	gb.joypActions = map[InputAction]func(pressed bool){
+		ACTION_UP:     gb.joyp.up.press,
+		ACTION_DOWN:   gb.joyp.down.press,
+		ACTION_RIGHT:  gb.joyp.right.press,
+		ACTION_LEFT:   gb.joyp.left.press,
+		ACTION_A:      gb.joyp.a.press,
+		ACTION_B:      gb.joyp.b.press,
+		ACTION_SELECT: gb.joyp.sel.press,
+		ACTION_START:  gb.joyp.start.press,
 	}
+
+	return nil
 }
 
 func (gb *Gameboy) printRegisters() {
@@ -152,59 +271,269 @@ func (gb *Gameboy) printRegisters() {
 	fmt.Println()
 }
 
-func (gb *Gameboy) Start() {
+// Start runs the game until the window is closed or the game loop fails. The save is flushed either way
+func (gb *Gameboy) Start() (err error) {
 	fmt.Println("Starting...")
 
 	ebiten.SetWindowSize(gb.windowWidth, gb.windowHeight)
 	ebiten.SetWindowTitle(fmt.Sprintf("GameboyGo - %s", gb.cart.title))
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
-	ebiten.SetTPS(FPS)
+	gb.setSpeed(1)
 	if !gb.opts.DebugMode {
 		ebiten.SetVsyncEnabled(true)
 	} else {
 		ebiten.SetVsyncEnabled(false)
 	}
 
-	defer gb.cart.syncSave()
+	defer func() {
+		err = errors.Join(err, gb.cart.syncSave())
+	}()
+	if gb.movie != nil {
+		defer func() {
+			err = errors.Join(err, gb.movie.close())
+		}()
+	}
 
 	if !gb.hasBootRom() {
 		gb.powerUpSequence()
 	}
 
-	if err := ebiten.RunGame(gb); err != nil {
-		log.Fatal(err)
-	}
+	return ebiten.RunGame(gb)
 }
 
 func (gb *Gameboy) Update() error {
+	// the debug panels read and write the bus outside of runFrame
+	defer gb.recoverFault()
+
+	if gb.rebindScreen.open {
+		gb.rebindScreen.handleInput()
+		return nil
+	}
+
+	if gb.cheatScreen.open {
+		gb.cheatScreen.handleInput()
+		return nil
+	}
+
 	gb.handleUIEvents()
+	gb.framesRun = 0
+
+	if gb.crashed {
+		// keep the window open on the last frame, with the fault in the title
+		return nil
+	}
+
+	if gb.paused {
+		if gb.advanceFrame {
+			gb.advanceFrame = false
+			return gb.runFrame()
+		}
+
+		return nil
+	}
+
+	if gb.fastForward {
+		return gb.runFastForward()
+	}
+
+	return gb.runFrame()
+}
+
+// runFrame emulates a single frame, an error stops the game loop
+func (gb *Gameboy) runFrame() error {
+	defer gb.recoverFault()
+
+	if gb.liveInput() {
+		gb.updateJoypad()
+	}
+
+	if gb.movie != nil {
+		if err := gb.movie.input(gb.joyp); err != nil {
+			return err
+		}
+	}
 
 	for gb.cpu.ticks < TICKS_PER_FRAME {
-		ticksThisUpdate := 4
-		if !gb.cpu.halted {
-			ticksThisUpdate = gb.cpu.step()
+		if gb.cpu.stopped {
+			gb.stepStopped()
+			continue
+		}
+
+		if gb.cpu.locked {
+			// only the CPU hangs, the rest of the system keeps running
+			gb.tickSystem(M_CYCLE_TICKS)
+			continue
 		}
 
-		gb.ppu.step(ticksThisUpdate)
-		gb.timer.step(ticksThisUpdate)
-		gb.dmac.step(ticksThisUpdate)
+		if gb.cpu.halted {
+			gb.tickSystem(M_CYCLE_TICKS)
+		} else {
+			gb.cpu.step()
+			if gb.cpu.locked {
+				gb.reportLockup()
+			}
+		}
 
-		gb.cpu.ticks += (ticksThisUpdate + gb.ic.handleIntrupts())
+		gb.ic.handleIntrupts()
 	}
 
 	gb.cpu.ticks -= TICKS_PER_FRAME
+	gb.framesRun++
+
+	if gb.movie != nil {
+		// stops the game loop, so a desync is never played past
+		err := gb.movie.endFrame(gb.stateHash)
+		gb.cheats.locked = !gb.movie.finished
+		return err
+	}
 
 	return nil
 }
 
+func (gb *Gameboy) reportLockup() {
+	pc := gb.cpu.lockedPC
+
+	var bank uint32
+	if pc <= ROM_TOP {
+		bank = gb.cart.romBank(pc)
+	} else if inRange(pc, EXT_RAM_BASE, EXT_RAM_TOP) {
+		bank = gb.cart.ramBank()
+	}
+
+	// report the byte as stored, not as seen through the PPU access locks or Game Genie patches
+	var opcode uint8
+	if gb.mmu.addrSpace(pc) == Addressable(gb.cart) {
+		opcode = gb.cart.readMapped(pc)
+	} else if p := gb.ppu.memPtr(pc); p != nil {
+		opcode = *p
+	} else {
+		opcode = gb.mmu.read(pc)
+	}
+
+	gb.fault = &CPULockupError{Bank: bank, PC: pc, Opcode: opcode}
+}
+
+// Fault returns what stopped the emulated CPU or the whole emulator from running, if anything has
+func (gb *Gameboy) Fault() error {
+	return gb.fault
+}
+
+// enterStopMode is called when the CPU executes STOP. If a speed switch was armed through KEY1,
+// STOP only lasts until the switch is done instead of waiting for the joypad
+func (gb *Gameboy) enterStopMode() {
+	gb.timer.write(DIV_ADDR, 0)
+
+	if gb.key1 != nil && gb.key1.trigger() {
+		gb.switchTicks = SPEED_SWITCH_TICKS
+	}
+}
+
+// stepStopped lets time pass while in STOP mode. DIV and the LCD are halted but a running OAM DMA carries on
+func (gb *Gameboy) stepStopped() {
+	gb.dmac.step(M_CYCLE_TICKS)
+	gb.cpu.ticks += gb.lcdTicks(M_CYCLE_TICKS)
+
+	if gb.switchTicks > 0 {
+		gb.switchTicks -= M_CYCLE_TICKS
+		if gb.switchTicks <= 0 {
+			gb.cpu.exitStoppedState()
+		}
+		return
+	}
+
+	if gb.joyp.selectedPressed() {
+		gb.cpu.exitStoppedState()
+	}
+}
+
+// tickSystem advances everything but the CPU, the CPU calls it for every M-cycle it spends
+func (gb *Gameboy) tickSystem(cTicks int) {
+	gb.ppu.step(gb.lcdTicks(cTicks))
+	gb.timer.step(cTicks)
+	gb.dmac.step(cTicks)
+
+	gb.cpu.ticks += gb.lcdTicks(cTicks)
+}
+
+// lcdTicks converts CPU clock ticks to PPU ticks, which frames are timed in. In double speed the CPU,
+// timer and DMA run twice as fast while the PPU keeps its speed
+func (gb *Gameboy) lcdTicks(cTicks int) int {
+	if gb.key1 != nil && gb.key1.double {
+		return cTicks / 2
+	}
+
+	return cTicks
+}
+
 func (gb *Gameboy) handleUIEvents() {
-	for kbKey, press := range gb.btnMappings {
-		press(ebiten.IsKeyPressed(kbKey))
+	if gb.opts.DebugMode {
+		gb.memViewer.handleInput()
+		gb.ramSearch.handleInput()
+		gb.oamViewer.handleInput()
+		gb.tileViewer.handleInput()
+		gb.rasterViewer.handleInput()
+
+		if gb.debugPanelFocused() {
+			// typing into a debug panel should not also drive the joypad
+			return
+		}
+	}
+
+	gb.input.update()
+
+	if gb.liveInput() {
+		for _, m := range gb.input.macros {
+			if m.triggered() {
+				gb.macros.start(m)
+			}
+		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyD) {
-		gb.toggleSpeed()
+	if gb.input.justPressed(ACTION_TURBO_RATE) {
+		gb.turbo.cycleRate()
+		gb.input.turboRate = gb.turbo.rate
+		gb.input.save()
 	}
+
+	gb.handleSpeedEvents()
+
+	if gb.input.justPressed(ACTION_REBIND) {
+		gb.rebindScreen.show()
+	}
+
+	if gb.input.justPressed(ACTION_CHEATS) {
+		gb.cheatScreen.show()
+	}
+}
+
+// liveInput reports whether the joypad is driven by the keyboard and gamepads, rather than a movie
+// or nothing at all while a debug panel has focus
+func (gb *Gameboy) liveInput() bool {
+	if gb.movie != nil && gb.movie.playing() {
+		return false
+	}
+
+	return !gb.opts.DebugMode || !gb.debugPanelFocused()
+}
+
+// updateJoypad presses each button if it is held, driven by its turbo binding or part of the running macro.
+// It is called once per emulated frame so turbo and macros keep their timing when fast forwarding
+func (gb *Gameboy) updateJoypad() {
+	macroState := gb.macros.next()
+
+	for action, press := range gb.joypActions {
+		held := gb.input.pressed(action) || bits.IsSet(macroState, joypadActionBits[action])
+
+		if turbo, ok := turboActions[action]; ok {
+			held = gb.turbo.update(turbo, gb.input.pressed(turbo)) || held
+		}
+
+		press(held)
+	}
+}
+
+func (gb *Gameboy) debugPanelFocused() bool {
+	return gb.memViewer.focused || gb.ramSearch.focused
 }
 
 func (gb *Gameboy) powerUpSequence() {
@@ -272,22 +601,55 @@ func (gb *Gameboy) powerUpSequence() {
 }
 
 func (gb *Gameboy) Draw(screen *ebiten.Image) {
+	defer gb.recoverFault()
+
 	gb.updateWindow()
 
 	if !gb.opts.DebugMode {
 		gb.ppu.updateGBScreen(screen, &ebiten.DrawImageOptions{})
+
+		gb.drawOverlays(screen, &ebiten.DrawImageOptions{})
 	} else {
 		opt := ebiten.DrawImageOptions{}
 		dbgOpt := ebiten.DrawImageOptions{}
 
-		opt.GeoM.Translate(0, (TILE_DATA_SCREEN_HEIGHT-GB_SCREEN_HEIGHT)/2)
+		opt.GeoM.Translate(0, GB_SCREEN_DBG_Y)
 		gb.ppu.updateGBScreen(screen, &opt)
 
-		dbgOpt.GeoM.Translate(GB_SCREEN_WIDTH, 0)
-		gb.ppu.updateTileDataScreen(screen, &dbgOpt)
+		gb.drawOverlays(screen, &opt)
+
+		dbgOpt.GeoM.Translate(TILE_DATA_DBG_X, 0)
+		gb.tileViewer.draw(screen, &dbgOpt)
 
 		dbgOpt.GeoM.Translate(TILE_DATA_SCREEN_WIDTH, 0)
 		gb.ppu.updateTileMaps(screen, &dbgOpt)
+
+		searchOpt := ebiten.DrawImageOptions{}
+		searchOpt.GeoM.Translate(RAM_SEARCH_DBG_X, 0)
+		gb.ramSearch.draw(screen, &searchOpt)
+
+		infoOpt := ebiten.DrawImageOptions{}
+		infoOpt.GeoM.Translate(0, GB_SCREEN_DBG_Y+GB_SCREEN_HEIGHT)
+		gb.ppu.updateTileMapInfo(screen, &infoOpt)
+
+		panelOpt := ebiten.DrawImageOptions{}
+		panelOpt.GeoM.Translate(0, DBG_PANEL_ROW_Y)
+		gb.memViewer.draw(screen, &panelOpt)
+
+		panelOpt.GeoM.Translate(MEM_VIEWER_SCREEN_WIDTH, 0)
+		gb.oamViewer.draw(screen, &panelOpt)
+
+		panelOpt.GeoM.Translate(OAM_VIEWER_SCREEN_WIDTH, 0)
+		gb.rasterViewer.draw(screen, &panelOpt)
+	}
+}
+
+// drawOverlays draws whichever menu is open over the game screen
+func (gb *Gameboy) drawOverlays(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	if gb.rebindScreen.open {
+		gb.rebindScreen.draw(screen, opt)
+	} else if gb.cheatScreen.open {
+		gb.cheatScreen.draw(screen, opt)
 	}
 }
 
@@ -296,10 +658,21 @@ func (gb *Gameboy) updateWindow() {
 
 	stats := ""
 	if gb.opts.Stats {
-		stats = fmt.Sprintf("(FPS: %s, SPEED: 1x)", strconv.Itoa(int(ebiten.ActualFPS())))
-		if gb.speedUp {
-			stats = strings.Replace(stats, "1x", "2x", 1)
+		stats = fmt.Sprintf("(FPS: %s, SPEED: %s)", strconv.Itoa(int(ebiten.ActualFPS())), gb.speedString())
+	} else if gb.paused || gb.fastForward || gb.speed != 1 {
+		stats = fmt.Sprintf("(SPEED: %s)", gb.speedString())
+	}
+
+	if gb.movie != nil && !gb.movie.finished {
+		mode := "[PLAY]"
+		if gb.movie.recording {
+			mode = "[REC]"
 		}
+		stats = strings.TrimSpace(strings.Join([]string{stats, mode}, " "))
+	}
+
+	if gb.fault != nil {
+		stats = strings.TrimSpace(strings.Join([]string{stats, fmt.Sprintf("[%s]", gb.fault)}, " "))
 	}
 
 	ebiten.SetWindowTitle(strings.Join([]string{emu, stats}, " "))
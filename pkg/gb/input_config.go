@@ -0,0 +1,362 @@
+package gb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// InputConfig maps keyboard keys and standard layout gamepad buttons/axes to joypad buttons and emulator hotkeys.
+// It is stored as JSON under the user config directory so it can be edited by hand or from the rebinding screen
+type InputConfig struct {
+	path     string
+	bindings map[InputAction]*InputBinding
+	held     map[InputAction]bool
+	prevHeld map[InputAction]bool
+	gamepads []ebiten.GamepadID
+
+	turboRate    int // frames between each toggle of a turbo button
+	macros       []*Macro
+	macroConfigs []MacroConfig // written back as is, macros are only edited by hand
+}
+
+type InputAction string
+
+// InputBinding is the resolved form of a BindingConfig
+type InputBinding struct {
+	keys    []ebiten.Key
+	buttons []ebiten.StandardGamepadButton
+	axes    []GamepadAxisDir
+}
+
+type GamepadAxisDir struct {
+	axis     ebiten.StandardGamepadAxis
+	positive bool
+}
+
+// BindingConfig is how a binding is stored in the config file, e.g.
+// {"keys": ["ArrowUp"], "gamepad": ["LeftTop", "-LeftStickVertical"]}
+type BindingConfig struct {
+	Keys    []string `json:"keys"`
+	Gamepad []string `json:"gamepad"`
+}
+
+type inputConfigFile struct {
+	Bindings  map[InputAction]BindingConfig `json:"bindings"`
+	TurboRate int                           `json:"turbo_rate"`
+	Macros    []MacroConfig                 `json:"macros"`
+}
+
+const (
+	ACTION_UP            InputAction = "up"
+	ACTION_DOWN          InputAction = "down"
+	ACTION_LEFT          InputAction = "left"
+	ACTION_RIGHT         InputAction = "right"
+	ACTION_A             InputAction = "a"
+	ACTION_B             InputAction = "b"
+	ACTION_START         InputAction = "start"
+	ACTION_SELECT        InputAction = "select"
+	ACTION_TURBO_A       InputAction = "turbo_a"
+	ACTION_TURBO_B       InputAction = "turbo_b"
+	ACTION_TURBO_RATE    InputAction = "turbo_rate"
+	ACTION_PAUSE         InputAction = "pause"
+	ACTION_FRAME_ADVANCE InputAction = "frame_advance"
+	ACTION_SLOW_MOTION   InputAction = "slow_motion"
+	ACTION_FAST_FORWARD  InputAction = "fast_forward"
+	ACTION_SPEED         InputAction = "speed"
+	ACTION_REBIND        InputAction = "rebind"
+	ACTION_CHEATS        InputAction = "cheats"
+
+	CONFIG_DIR        = "GameboyGo"
+	INPUT_CONFIG_FILE = "input.json"
+
+	GAMEPAD_AXIS_DEADZONE = 0.5
+)
+
+// every action in the order they are listed on the rebinding screen
+var inputActions = []InputAction{
+	ACTION_UP, ACTION_DOWN, ACTION_LEFT, ACTION_RIGHT,
+	ACTION_A, ACTION_B, ACTION_START, ACTION_SELECT,
+	ACTION_TURBO_A, ACTION_TURBO_B, ACTION_TURBO_RATE,
+	ACTION_PAUSE, ACTION_FRAME_ADVANCE, ACTION_SLOW_MOTION, ACTION_FAST_FORWARD,
+	ACTION_SPEED, ACTION_REBIND, ACTION_CHEATS,
+}
+
+var defaultBindings = map[InputAction]BindingConfig{
+	ACTION_UP:            {Keys: []string{"ArrowUp"}, Gamepad: []string{"LeftTop", "-LeftStickVertical"}},
+	ACTION_DOWN:          {Keys: []string{"ArrowDown"}, Gamepad: []string{"LeftBottom", "+LeftStickVertical"}},
+	ACTION_LEFT:          {Keys: []string{"ArrowLeft"}, Gamepad: []string{"LeftLeft", "-LeftStickHorizontal"}},
+	ACTION_RIGHT:         {Keys: []string{"ArrowRight"}, Gamepad: []string{"LeftRight", "+LeftStickHorizontal"}},
+	ACTION_A:             {Keys: []string{"A"}, Gamepad: []string{"RightRight"}},
+	ACTION_B:             {Keys: []string{"S"}, Gamepad: []string{"RightBottom"}},
+	ACTION_START:         {Keys: []string{"Enter"}, Gamepad: []string{"CenterRight"}},
+	ACTION_SELECT:        {Keys: []string{"Space"}, Gamepad: []string{"CenterLeft"}},
+	ACTION_TURBO_A:       {Keys: []string{"Q"}, Gamepad: []string{"RightTop"}},
+	ACTION_TURBO_B:       {Keys: []string{"W"}, Gamepad: []string{"RightLeft"}},
+	ACTION_TURBO_RATE:    {Keys: []string{"T"}, Gamepad: []string{}},
+	ACTION_PAUSE:         {Keys: []string{"P"}, Gamepad: []string{}},
+	ACTION_FRAME_ADVANCE: {Keys: []string{"N"}, Gamepad: []string{}},
+	ACTION_SLOW_MOTION:   {Keys: []string{"Minus"}, Gamepad: []string{}},
+	ACTION_FAST_FORWARD:  {Keys: []string{"Tab"}, Gamepad: []string{"FrontTopLeft"}},
+	ACTION_SPEED:         {Keys: []string{"D"}, Gamepad: []string{"FrontTopRight"}},
+	ACTION_REBIND:        {Keys: []string{"F1"}, Gamepad: []string{}},
+	ACTION_CHEATS:        {Keys: []string{"F2"}, Gamepad: []string{}},
+}
+
+var gamepadButtonNames = map[ebiten.StandardGamepadButton]string{
+	ebiten.StandardGamepadButtonRightBottom:      "RightBottom",
+	ebiten.StandardGamepadButtonRightRight:       "RightRight",
+	ebiten.StandardGamepadButtonRightLeft:        "RightLeft",
+	ebiten.StandardGamepadButtonRightTop:         "RightTop",
+	ebiten.StandardGamepadButtonFrontTopLeft:     "FrontTopLeft",
+	ebiten.StandardGamepadButtonFrontTopRight:    "FrontTopRight",
+	ebiten.StandardGamepadButtonFrontBottomLeft:  "FrontBottomLeft",
+	ebiten.StandardGamepadButtonFrontBottomRight: "FrontBottomRight",
+	ebiten.StandardGamepadButtonCenterLeft:       "CenterLeft",
+	ebiten.StandardGamepadButtonCenterRight:      "CenterRight",
+	ebiten.StandardGamepadButtonLeftStick:        "LeftStick",
+	ebiten.StandardGamepadButtonRightStick:       "RightStick",
+	ebiten.StandardGamepadButtonLeftTop:          "LeftTop",
+	ebiten.StandardGamepadButtonLeftBottom:       "LeftBottom",
+	ebiten.StandardGamepadButtonLeftLeft:         "LeftLeft",
+	ebiten.StandardGamepadButtonLeftRight:        "LeftRight",
+	ebiten.StandardGamepadButtonCenterCenter:     "CenterCenter",
+}
+
+var gamepadAxisNames = map[ebiten.StandardGamepadAxis]string{
+	ebiten.StandardGamepadAxisLeftStickHorizontal:  "LeftStickHorizontal",
+	ebiten.StandardGamepadAxisLeftStickVertical:    "LeftStickVertical",
+	ebiten.StandardGamepadAxisRightStickHorizontal: "RightStickHorizontal",
+	ebiten.StandardGamepadAxisRightStickVertical:   "RightStickVertical",
+}
+
+func loadInputConfig() (*InputConfig, error) {
+	cfg := &InputConfig{
+		bindings: make(map[InputAction]*InputBinding),
+		held:     make(map[InputAction]bool),
+		prevHeld: make(map[InputAction]bool),
+	}
+
+	var stored inputConfigFile
+	if dir, err := os.UserConfigDir(); err == nil {
+		cfg.path = filepath.Join(dir, CONFIG_DIR, INPUT_CONFIG_FILE)
+
+		data, err := os.ReadFile(cfg.path)
+		if err == nil {
+			if err := json.Unmarshal(data, &stored); err != nil {
+				return nil, fmt.Errorf("could not parse input config %s: %w", cfg.path, err)
+			}
+
+			if stored.Bindings == nil && stored.TurboRate == 0 && stored.Macros == nil {
+				// older configs only held the bindings
+				if err := json.Unmarshal(data, &stored.Bindings); err != nil {
+					return nil, fmt.Errorf("could not parse input config %s: %w", cfg.path, err)
+				}
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	} else {
+		fmt.Println("No user config directory, using the default input bindings:", err)
+	}
+
+	// anything missing from the file gets its default, the file itself is only written once something is changed
+	for _, action := range inputActions {
+		bc, ok := stored.Bindings[action]
+		if !ok {
+			bc = defaultBindings[action]
+		}
+
+		cfg.bindings[action] = parseBinding(string(action), bc)
+	}
+
+	cfg.turboRate = stored.TurboRate
+	if cfg.turboRate < 1 {
+		cfg.turboRate = DEFAULT_TURBO_RATE
+	}
+
+	cfg.macroConfigs = stored.Macros
+	if cfg.macroConfigs == nil {
+		cfg.macroConfigs = []MacroConfig{}
+	}
+
+	for _, mc := range cfg.macroConfigs {
+		cfg.macros = append(cfg.macros, parseMacro(mc))
+	}
+
+	return cfg, nil
+}
+
+func parseBinding(action string, bc BindingConfig) *InputBinding {
+	binding := &InputBinding{}
+
+	for _, name := range bc.Keys {
+		var key ebiten.Key
+		if err := key.UnmarshalText([]byte(name)); err != nil {
+			fmt.Printf("Ignoring unknown key %q bound to %s\n", name, action)
+			continue
+		}
+
+		binding.keys = append(binding.keys, key)
+	}
+
+	for _, name := range bc.Gamepad {
+		if btn, ok := gamepadButtonByName(name); ok {
+			binding.buttons = append(binding.buttons, btn)
+		} else if axis, ok := gamepadAxisByName(name); ok {
+			binding.axes = append(binding.axes, axis)
+		} else {
+			fmt.Printf("Ignoring unknown gamepad input %q bound to %s\n", name, action)
+		}
+	}
+
+	return binding
+}
+
+func gamepadButtonByName(name string) (ebiten.StandardGamepadButton, bool) {
+	for btn, btnName := range gamepadButtonNames {
+		if btnName == name {
+			return btn, true
+		}
+	}
+
+	return 0, false
+}
+
+// gamepadAxisByName parses an axis direction, such as "-LeftStickVertical" for pushing the left stick up
+func gamepadAxisByName(name string) (GamepadAxisDir, bool) {
+	if len(name) < 2 || (name[0] != '+' && name[0] != '-') {
+		return GamepadAxisDir{}, false
+	}
+
+	for axis, axisName := range gamepadAxisNames {
+		if axisName == name[1:] {
+			return GamepadAxisDir{axis: axis, positive: name[0] == '+'}, true
+		}
+	}
+
+	return GamepadAxisDir{}, false
+}
+
+func (dir GamepadAxisDir) String() string {
+	if dir.positive {
+		return "+" + gamepadAxisNames[dir.axis]
+	}
+
+	return "-" + gamepadAxisNames[dir.axis]
+}
+
+func (b *InputBinding) config() BindingConfig {
+	bc := BindingConfig{Keys: []string{}, Gamepad: []string{}}
+
+	for _, key := range b.keys {
+		bc.Keys = append(bc.Keys, key.String())
+	}
+
+	for _, btn := range b.buttons {
+		bc.Gamepad = append(bc.Gamepad, gamepadButtonNames[btn])
+	}
+
+	for _, axis := range b.axes {
+		bc.Gamepad = append(bc.Gamepad, axis.String())
+	}
+
+	return bc
+}
+
+func (b *InputBinding) String() string {
+	bc := b.config()
+	return strings.Join(append(bc.Keys, bc.Gamepad...), ", ")
+}
+
+func (cfg *InputConfig) save() {
+	if cfg.path == "" {
+		return
+	}
+
+	stored := inputConfigFile{
+		Bindings:  make(map[InputAction]BindingConfig, len(cfg.bindings)),
+		TurboRate: cfg.turboRate,
+		Macros:    cfg.macroConfigs,
+	}
+
+	for action, binding := range cfg.bindings {
+		stored.Bindings[action] = binding.config()
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		fmt.Println("Could not save input config:", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.path), os.ModePerm); err != nil {
+		fmt.Println("Could not save input config:", err)
+		return
+	}
+
+	if err := os.WriteFile(cfg.path, data, 0644); err != nil {
+		fmt.Println("Could not save input config:", err)
+		return
+	}
+
+	fmt.Printf("Saved input config to %s\n", cfg.path)
+}
+
+// update polls every binding, it is called once per frame before any of the pressed checks
+func (cfg *InputConfig) update() {
+	cfg.gamepads = ebiten.AppendGamepadIDs(cfg.gamepads[:0])
+
+	for _, action := range inputActions {
+		cfg.prevHeld[action] = cfg.held[action]
+		cfg.held[action] = cfg.bindingPressed(cfg.bindings[action])
+	}
+
+	for _, m := range cfg.macros {
+		m.prevHeld = m.held
+		m.held = cfg.bindingPressed(m.hotkey)
+	}
+}
+
+func (cfg *InputConfig) bindingPressed(binding *InputBinding) bool {
+	for _, key := range binding.keys {
+		if ebiten.IsKeyPressed(key) {
+			return true
+		}
+	}
+
+	for _, id := range cfg.gamepads {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			continue
+		}
+
+		for _, btn := range binding.buttons {
+			if ebiten.IsStandardGamepadButtonPressed(id, btn) {
+				return true
+			}
+		}
+
+		for _, dir := range binding.axes {
+			val := ebiten.StandardGamepadAxisValue(id, dir.axis)
+			if (dir.positive && val > GAMEPAD_AXIS_DEADZONE) || (!dir.positive && val < -GAMEPAD_AXIS_DEADZONE) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (cfg *InputConfig) pressed(action InputAction) bool {
+	return cfg.held[action]
+}
+
+func (cfg *InputConfig) justPressed(action InputAction) bool {
+	return cfg.held[action] && !cfg.prevHeld[action]
+}
@@ -1,10 +1,6 @@
 package gb
 
-import (
-	"log"
-
-	"github.com/BeralaWoolies/GameboyGo/pkg/bits"
-)
+import "github.com/BeralaWoolies/GameboyGo/pkg/bits"
 
 var instrBaseTicks = [0x100]int{
 	0x04, 0x0C, 0x08, 0x08, 0x04, 0x04, 0x08, 0x04, 0x14, 0x08, 0x08, 0x08, 0x04, 0x04, 0x08, 0x04,
@@ -161,7 +157,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x02: func(cpu *CPU) int {
 		// LD [BC], A
 		// fmt.Println("Decoded OPCODE: LD [BC], A")
-		cpu.mmu.write(cpu.getBC(), cpu.reg.A)
+		cpu.write(cpu.getBC(), cpu.reg.A)
 		return 0
 	},
 	0x03: func(cpu *CPU) int {
@@ -204,8 +200,8 @@ var instructions = [0x100]func(cpu *CPU) int{
 		// LD [u16], SP
 		// fmt.Println("Decoded OPCODE: LD [u16], SP")
 		address := cpu.nextPC16()
-		cpu.mmu.write(address, bits.LoByte(cpu.reg.SP))
-		cpu.mmu.write(address+1, bits.HiByte(cpu.reg.SP))
+		cpu.write(address, bits.LoByte(cpu.reg.SP))
+		cpu.write(address+1, bits.HiByte(cpu.reg.SP))
 		return 0
 	},
 	0x09: func(cpu *CPU) int {
@@ -217,7 +213,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x0A: func(cpu *CPU) int {
 		// LD A, [BC]
 		// fmt.Println("Decoded OPCODE: LD A, [BC]")
-		cpu.setA(cpu.mmu.read(cpu.getBC()))
+		cpu.setA(cpu.read(cpu.getBC()))
 		return 0
 	},
 	0x0B: func(cpu *CPU) int {
@@ -259,8 +255,8 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x10: func(cpu *CPU) int {
 		// STOP
 		// fmt.Println("Decoded OPCODE: STOP")
-		cpu.enterHaltedState()
 		cpu.nextPC()
+		cpu.enterStoppedState()
 		return 0
 	},
 	0x11: func(cpu *CPU) int {
@@ -272,7 +268,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x12: func(cpu *CPU) int {
 		// LD [DE], A
 		// fmt.Println("Decoded OPCODE: LD [DE], A")
-		cpu.mmu.write(cpu.getDE(), cpu.reg.A)
+		cpu.write(cpu.getDE(), cpu.reg.A)
 		return 0
 	},
 	0x13: func(cpu *CPU) int {
@@ -332,7 +328,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x1A: func(cpu *CPU) int {
 		// LD A, [DE]
 		// fmt.Println("Decoded OPCODE: LD A, [DE]")
-		cpu.setA(cpu.mmu.read(cpu.getDE()))
+		cpu.setA(cpu.read(cpu.getDE()))
 		return 0
 	},
 	0x1B: func(cpu *CPU) int {
@@ -396,7 +392,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x22: func(cpu *CPU) int {
 		// LD [HL+], A
 		// fmt.Println("Decoded OPCODE: LD [HL+], A")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.A)
+		cpu.write(cpu.getHL(), cpu.reg.A)
 		cpu.setHL(cpu.getHL() + 1)
 		return 0
 	},
@@ -468,7 +464,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x2A: func(cpu *CPU) int {
 		// LD A, [HL+]
 		// fmt.Println("Decoded OPCODE: LD A, [HL+]")
-		cpu.setA(cpu.mmu.read(cpu.getHL()))
+		cpu.setA(cpu.read(cpu.getHL()))
 		cpu.setHL(cpu.getHL() + 1)
 		return 0
 	},
@@ -524,7 +520,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x32: func(cpu *CPU) int {
 		// LD [HL-], A
 		// fmt.Println("Decoded OPCODE: LD [HL-], A")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.A)
+		cpu.write(cpu.getHL(), cpu.reg.A)
 		cpu.setHL(cpu.getHL() - 1)
 		return 0
 	},
@@ -537,21 +533,21 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x34: func(cpu *CPU) int {
 		// INC [HL]
 		// fmt.Println("Decoded OPCODE: INC [HL]")
-		val := cpu.mmu.read(cpu.getHL())
-		cpu.instrInc(func(result uint8) { cpu.mmu.write(cpu.getHL(), result) }, val)
+		val := cpu.read(cpu.getHL())
+		cpu.instrInc(func(result uint8) { cpu.write(cpu.getHL(), result) }, val)
 		return 0
 	},
 	0x35: func(cpu *CPU) int {
 		// DEC [HL]
 		// fmt.Println("Decoded OPCODE: DEC [HL]")
-		val := cpu.mmu.read(cpu.getHL())
-		cpu.instrDec(func(result uint8) { cpu.mmu.write(cpu.getHL(), result) }, val)
+		val := cpu.read(cpu.getHL())
+		cpu.instrDec(func(result uint8) { cpu.write(cpu.getHL(), result) }, val)
 		return 0
 	},
 	0x36: func(cpu *CPU) int {
 		// LD [HL], u8
 		// fmt.Println("Decoded OPCODE: LD [HL], u8")
-		cpu.mmu.write(cpu.getHL(), cpu.nextPC())
+		cpu.write(cpu.getHL(), cpu.nextPC())
 		return 0
 	},
 	0x37: func(cpu *CPU) int {
@@ -582,7 +578,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x3A: func(cpu *CPU) int {
 		// LD A, [HL-]
 		// fmt.Println("Decoded OPCODE: LD A, [HL-]")
-		cpu.setA(cpu.mmu.read(cpu.getHL()))
+		cpu.setA(cpu.read(cpu.getHL()))
 		cpu.setHL(cpu.getHL() - 1)
 		return 0
 	},
@@ -657,7 +653,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x46: func(cpu *CPU) int {
 		// LD B, [HL]
 		// fmt.Println("Decoded OPCODE: LD B, [HL]")
-		cpu.setB(cpu.mmu.read(cpu.getHL()))
+		cpu.setB(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0x47: func(cpu *CPU) int {
@@ -705,7 +701,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x4E: func(cpu *CPU) int {
 		// LD C, [HL]
 		// fmt.Println("Decoded OPCODE: LD C, [HL]")
-		cpu.setC(cpu.mmu.read(cpu.getHL()))
+		cpu.setC(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0x4F: func(cpu *CPU) int {
@@ -753,7 +749,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x56: func(cpu *CPU) int {
 		// LD D, [HL]
 		// fmt.Println("Decoded OPCODE: LD D, [HL]")
-		cpu.setD(cpu.mmu.read(cpu.getHL()))
+		cpu.setD(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0x57: func(cpu *CPU) int {
@@ -801,7 +797,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x5E: func(cpu *CPU) int {
 		// LD E, [HL]
 		// fmt.Println("Decoded OPCODE: LD E, [HL]")
-		cpu.setE(cpu.mmu.read(cpu.getHL()))
+		cpu.setE(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0x5F: func(cpu *CPU) int {
@@ -849,7 +845,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x66: func(cpu *CPU) int {
 		// LD H, [HL]
 		// fmt.Println("Decoded OPCODE: LD H, [HL]")
-		cpu.setH(cpu.mmu.read(cpu.getHL()))
+		cpu.setH(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0x67: func(cpu *CPU) int {
@@ -897,7 +893,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x6E: func(cpu *CPU) int {
 		// LD L, [HL]
 		// fmt.Println("Decoded OPCODE: LD L, [HL]")
-		cpu.setL(cpu.mmu.read(cpu.getHL()))
+		cpu.setL(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0x6F: func(cpu *CPU) int {
@@ -909,49 +905,54 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x70: func(cpu *CPU) int {
 		// LD [HL], B
 		// fmt.Println("Decoded OPCODE: LD [HL], B")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.B)
+		cpu.write(cpu.getHL(), cpu.reg.B)
 		return 0
 	},
 	0x71: func(cpu *CPU) int {
 		// LD [HL], C
 		// fmt.Println("Decoded OPCODE: LD [HL], C")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.C)
+		cpu.write(cpu.getHL(), cpu.reg.C)
 		return 0
 	},
 	0x72: func(cpu *CPU) int {
 		// LD [HL], D
 		// fmt.Println("Decoded OPCODE: LD [HL], D")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.D)
+		cpu.write(cpu.getHL(), cpu.reg.D)
 		return 0
 	},
 	0x73: func(cpu *CPU) int {
 		// LD [HL], E
 		// fmt.Println("Decoded OPCODE: LD [HL], E")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.E)
+		cpu.write(cpu.getHL(), cpu.reg.E)
 		return 0
 	},
 	0x74: func(cpu *CPU) int {
 		// LD [HL], H
 		// fmt.Println("Decoded OPCODE: LD [HL], H")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.H)
+		cpu.write(cpu.getHL(), cpu.reg.H)
 		return 0
 	},
 	0x75: func(cpu *CPU) int {
 		// LD [HL], L
 		// fmt.Println("Decoded OPCODE: LD [HL], L")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.L)
+		cpu.write(cpu.getHL(), cpu.reg.L)
 		return 0
 	},
 	0x76: func(cpu *CPU) int {
 		// HALT
 		// fmt.Println("Decoded OPCODE: HALT")
+		if !cpu.IME && cpu.intruptPending() {
+			// HALT bug, the CPU does not halt and the byte after HALT is read twice
+			cpu.haltBug = true
+			return 0
+		}
 		cpu.enterHaltedState()
 		return 0
 	},
 	0x77: func(cpu *CPU) int {
 		// LD [HL], A
 		// fmt.Println("Decoded OPCODE: LD [HL], A")
-		cpu.mmu.write(cpu.getHL(), cpu.reg.A)
+		cpu.write(cpu.getHL(), cpu.reg.A)
 		return 0
 	},
 	0x78: func(cpu *CPU) int {
@@ -993,7 +994,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x7E: func(cpu *CPU) int {
 		// LD A, [HL]
 		// fmt.Println("Decoded OPCODE: LD A, [HL]")
-		cpu.setA(cpu.mmu.read(cpu.getHL()))
+		cpu.setA(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0x7F: func(cpu *CPU) int {
@@ -1041,7 +1042,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x86: func(cpu *CPU) int {
 		// ADD A, [HL]
 		// fmt.Println("Decoded OPCODE: ADD A, [HL]")
-		cpu.instrAddA(cpu.mmu.read(cpu.getHL()), false)
+		cpu.instrAddA(cpu.read(cpu.getHL()), false)
 		return 0
 	},
 	0x87: func(cpu *CPU) int {
@@ -1089,7 +1090,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x8E: func(cpu *CPU) int {
 		// ADC A, [HL]
 		// fmt.Println("Decoded OPCODE: ADC A, [HL]")
-		cpu.instrAddA(cpu.mmu.read(cpu.getHL()), true)
+		cpu.instrAddA(cpu.read(cpu.getHL()), true)
 		return 0
 	},
 	0x8F: func(cpu *CPU) int {
@@ -1137,7 +1138,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x96: func(cpu *CPU) int {
 		// SUB A, [HL]
 		// fmt.Println("Decoded OPCODE: SUB A, [HL]")
-		cpu.instrSubA(cpu.mmu.read(cpu.getHL()), false)
+		cpu.instrSubA(cpu.read(cpu.getHL()), false)
 		return 0
 	},
 	0x97: func(cpu *CPU) int {
@@ -1185,7 +1186,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0x9E: func(cpu *CPU) int {
 		// SBC A, [HL]
 		// fmt.Println("Decoded OPCODE: SBC A, [HL]")
-		cpu.instrSubA(cpu.mmu.read(cpu.getHL()), true)
+		cpu.instrSubA(cpu.read(cpu.getHL()), true)
 		return 0
 	},
 	0x9F: func(cpu *CPU) int {
@@ -1233,7 +1234,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xA6: func(cpu *CPU) int {
 		// AND A, [HL]
 		// fmt.Println("Decoded OPCODE: AND A, [HL]")
-		cpu.instrAndA(cpu.mmu.read(cpu.getHL()))
+		cpu.instrAndA(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0xA7: func(cpu *CPU) int {
@@ -1281,7 +1282,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xAE: func(cpu *CPU) int {
 		// XOR A, [HL]
 		// fmt.Println("Decoded OPCODE: XOR A, [HL]")
-		cpu.instrXorA(cpu.mmu.read(cpu.getHL()))
+		cpu.instrXorA(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0xAF: func(cpu *CPU) int {
@@ -1329,7 +1330,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xB6: func(cpu *CPU) int {
 		// OR A, [HL]
 		// fmt.Println("Decoded OPCODE: OR A, [HL]")
-		cpu.instrOrA(cpu.mmu.read(cpu.getHL()))
+		cpu.instrOrA(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0xB7: func(cpu *CPU) int {
@@ -1377,7 +1378,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xBE: func(cpu *CPU) int {
 		// CP A, [HL]
 		// fmt.Println("Decoded OPCODE: CP A, [HL]")
-		cpu.instrCpA(cpu.mmu.read(cpu.getHL()))
+		cpu.instrCpA(cpu.read(cpu.getHL()))
 		return 0
 	},
 	0xBF: func(cpu *CPU) int {
@@ -1389,6 +1390,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xC0: func(cpu *CPU) int {
 		// RET NZ
 		// fmt.Println("Decoded OPCODE: RET NZ")
+		cpu.internalDelay() // the condition is checked on an M-cycle of its own
 		if !cpu.zFlag() {
 			cpu.instrRet()
 			return 12
@@ -1448,6 +1450,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xC8: func(cpu *CPU) int {
 		// RET Z
 		// fmt.Println("Decoded OPCODE: RET Z")
+		cpu.internalDelay() // the condition is checked on an M-cycle of its own
 		if cpu.zFlag() {
 			cpu.instrRet()
 			return 12
@@ -1511,6 +1514,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xD0: func(cpu *CPU) int {
 		// RET NC
 		// fmt.Println("Decoded OPCODE: RET NC")
+		cpu.internalDelay() // the condition is checked on an M-cycle of its own
 		if !cpu.cFlag() {
 			cpu.instrRet()
 			return 12
@@ -1536,6 +1540,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xD3: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xD3
 		// fmt.Println("ILLEGAL OPCODE: 0xD3")
+		cpu.lockUp()
 		return 0
 	},
 	0xD4: func(cpu *CPU) int {
@@ -1569,6 +1574,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xD8: func(cpu *CPU) int {
 		// RET C
 		// fmt.Println("Decoded OPCODE: RET C")
+		cpu.internalDelay() // the condition is checked on an M-cycle of its own
 		if cpu.cFlag() {
 			cpu.instrRet()
 			return 12
@@ -1579,7 +1585,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 		// RETI
 		// fmt.Println("Decoded OPCODE: RETI")
 		cpu.instrRet()
-		cpu.setIMEDelay()
+		cpu.setIME() // unlike EI there is no delay
 		return 0
 	},
 	0xDA: func(cpu *CPU) int {
@@ -1595,6 +1601,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xDB: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xDB
 		// fmt.Println("ILLEGAL OPCODE: 0xDB")
+		cpu.lockUp()
 		return 0
 	},
 	0xDC: func(cpu *CPU) int {
@@ -1610,6 +1617,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xDD: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xDD
 		// fmt.Println("ILLEGAL OPCODE: 0xDD")
+		cpu.lockUp()
 		return 0
 	},
 	0xDE: func(cpu *CPU) int {
@@ -1628,7 +1636,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 		// LD (FF00+u8), A
 		// fmt.Println("Decoded OPCODE: LD (FF00+u8), A")
 		address := 0xFF00 + uint16(cpu.nextPC())
-		cpu.mmu.write(address, cpu.reg.A)
+		cpu.write(address, cpu.reg.A)
 		return 0
 	},
 	0xE1: func(cpu *CPU) int {
@@ -1641,17 +1649,19 @@ var instructions = [0x100]func(cpu *CPU) int{
 		// LD (FF00+C), A
 		// fmt.Println("Decoded OPCODE: LD (FF00+C), A")
 		address := 0xFF00 + uint16(cpu.reg.C)
-		cpu.mmu.write(address, cpu.reg.A)
+		cpu.write(address, cpu.reg.A)
 		return 0
 	},
 	0xE3: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xE3
 		// fmt.Println("ILLEGAL OPCODE: 0xE3")
+		cpu.lockUp()
 		return 0
 	},
 	0xE4: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xE4
 		// fmt.Println("ILLEGAL OPCODE: 0xE4")
+		cpu.lockUp()
 		return 0
 	},
 	0xE5: func(cpu *CPU) int {
@@ -1687,22 +1697,25 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xEA: func(cpu *CPU) int {
 		// LD [u16], A
 		// fmt.Println("Decoded OPCODE: LD [u16], A")
-		cpu.mmu.write(cpu.nextPC16(), cpu.reg.A)
+		cpu.write(cpu.nextPC16(), cpu.reg.A)
 		return 0
 	},
 	0xEB: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xEB
 		// fmt.Println("ILLEGAL OPCODE: 0xEB")
+		cpu.lockUp()
 		return 0
 	},
 	0xEC: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xEC
 		// fmt.Println("ILLEGAL OPCODE: 0xEC")
+		cpu.lockUp()
 		return 0
 	},
 	0xED: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xED
 		// fmt.Println("ILLEGAL OPCODE: 0xED")
+		cpu.lockUp()
 		return 0
 	},
 	0xEE: func(cpu *CPU) int {
@@ -1721,7 +1734,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 		// LD A, (FF00+u8)
 		// fmt.Println("Decoded OPCODE: LD A, (FF00+u8)")
 		address := 0xFF00 + uint16(cpu.nextPC())
-		cpu.setA(cpu.mmu.read(address))
+		cpu.setA(cpu.read(address))
 		return 0
 	},
 	0xF1: func(cpu *CPU) int {
@@ -1734,7 +1747,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 		// LD A, (FF00+C)
 		// fmt.Println("Decoded OPCODE: LD A, (FF00+C)")
 		address := 0xFF00 + uint16(cpu.reg.C)
-		cpu.setA(cpu.mmu.read(address))
+		cpu.setA(cpu.read(address))
 		return 0
 	},
 	0xF3: func(cpu *CPU) int {
@@ -1746,6 +1759,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xF4: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xF4
 		// fmt.Println("ILLEGAL OPCODE: 0xF4")
+		cpu.lockUp()
 		return 0
 	},
 	0xF5: func(cpu *CPU) int {
@@ -1781,7 +1795,7 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xFA: func(cpu *CPU) int {
 		// LD A, [u16]
 		// fmt.Println("Decoded OPCODE: LD A, [u16]")
-		cpu.setA(cpu.mmu.read(cpu.nextPC16()))
+		cpu.setA(cpu.read(cpu.nextPC16()))
 		return 0
 	},
 	0xFB: func(cpu *CPU) int {
@@ -1793,11 +1807,13 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xFC: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xFC
 		// fmt.Println("ILLEGAL OPCODE: 0xFC")
+		cpu.lockUp()
 		return 0
 	},
 	0xFD: func(cpu *CPU) int {
 		// ILLEGAL OPCODE 0xFD
 		// fmt.Println("ILLEGAL OPCODE: 0xFD")
+		cpu.lockUp()
 		return 0
 	},
 	0xFE: func(cpu *CPU) int {
@@ -1809,9 +1825,6 @@ var instructions = [0x100]func(cpu *CPU) int{
 	0xFF: func(cpu *CPU) int {
 		// RST $38
 		// fmt.Println("Decoded OPCODE: RST $38")
-		if cpu.mmu.read(0x0038) == 0xFF {
-			log.Fatal("RST $38 loop")
-		}
 		cpu.instrCall(0x0038)
 		return 0
 	},
@@ -127,7 +127,7 @@ func (cpu *CPU) initCbInstructions() [0x100]func() {
 		3: cpu.setE,
 		4: cpu.setH,
 		5: cpu.setL,
-		6: func(result uint8) { cpu.mmu.write(cpu.getHL(), result) },
+		6: func(result uint8) { cpu.write(cpu.getHL(), result) },
 		7: cpu.setA,
 	}
 
@@ -138,7 +138,7 @@ func (cpu *CPU) initCbInstructions() [0x100]func() {
 		3: func() uint8 { return cpu.reg.E },
 		4: func() uint8 { return cpu.reg.H },
 		5: func() uint8 { return cpu.reg.L },
-		6: func() uint8 { return cpu.mmu.read(cpu.getHL()) },
+		6: func() uint8 { return cpu.read(cpu.getHL()) },
 		7: func() uint8 { return cpu.reg.A },
 	}
 
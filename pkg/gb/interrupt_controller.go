@@ -1,7 +1,7 @@
 package gb
 
 import (
-	"log"
+	"fmt"
 
 	"github.com/BeralaWoolies/GameboyGo/pkg/bits"
 )
@@ -29,8 +29,6 @@ const (
 	TIMER_INTRUPT_VEC  = 0x50
 	SERIAL_INTRUPT_VEC = 0x58
 	JOYPAD_INTRUPT_VEC = 0x60
-
-	ISR_CLOCK_TICKS = 20
 )
 
 func (ic *IntruptController) init(mmu *MMU, cpu *CPU) {
@@ -49,7 +47,7 @@ func (ic *IntruptController) read(addr uint16) uint8 {
 	case IE_ADDR:
 		return ic.intruptEnableReg | INTRUPT_MSK
 	default:
-		log.Fatalf("MMU mapped an illegal read address: 0x%02x to interrupt controller", addr)
+		busFault("interrupt controller", addr, false)
 		return 0xFF
 	}
 }
@@ -61,51 +59,51 @@ func (ic *IntruptController) write(addr uint16, data uint8) {
 	case IE_ADDR:
 		ic.intruptEnableReg = data | INTRUPT_MSK
 	default:
-		log.Fatalf("MMU mapped an illegal write address: 0x%02x to interrupt controller", addr)
+		busFault("interrupt controller", addr, true)
 	}
 }
 
-func (ic *IntruptController) handleIntrupts() int {
-	if ic.cpu.IMEDelay {
-		ic.cpu.clearIMEDelay()
-		ic.cpu.setIME()
-		return 0
+// handleIntrupts is called on instruction boundaries, and on every M-cycle while halted
+func (ic *IntruptController) handleIntrupts() {
+	if _, pending := ic.highestPending(); !pending {
+		return
 	}
 
-	if !ic.cpu.IME && !ic.cpu.halted {
-		return 0
+	if ic.cpu.halted {
+		// waking up from HALT takes an extra M-cycle
+		ic.cpu.exitHaltedState()
+		ic.cpu.internalDelay()
 	}
 
-	IE := ic.intruptEnableReg
-	IF := ic.intruptFlagReg
-
-	if bits.IsSetInBoth(IE, IF, VBLANK_INTRUPT_BIT) {
-		ic.serviceIntrupt(VBLANK_INTRUPT_BIT)
-	} else if bits.IsSetInBoth(IE, IF, LCD_INTRUPT_BIT) {
-		ic.serviceIntrupt(LCD_INTRUPT_BIT)
-	} else if bits.IsSetInBoth(IE, IF, TIMER_INTRUPT_BIT) {
-		ic.serviceIntrupt(TIMER_INTRUPT_BIT)
-	} else if bits.IsSetInBoth(IE, IF, SERIAL_INTRUPT_BIT) {
-		ic.serviceIntrupt(SERIAL_INTRUPT_BIT)
-	} else if bits.IsSetInBoth(IE, IF, JOYPAD_INTRUPT_BIT) {
-		ic.serviceIntrupt(JOYPAD_INTRUPT_BIT)
-	} else {
-		return 0
+	if ic.cpu.IME {
+		ic.dispatch()
 	}
-
-	return ISR_CLOCK_TICKS
 }
 
-func (ic *IntruptController) serviceIntrupt(intruptBit uint8) {
-	if !ic.cpu.IME && ic.cpu.halted {
-		ic.cpu.exitHaltedState()
+// dispatch takes 5 M-cycles: two wait states, the PC pushes and loading the vector
+func (ic *IntruptController) dispatch() {
+	ic.cpu.clearIME()
+	ic.cpu.internalDelay()
+	ic.cpu.internalDelay()
+
+	pc := ic.cpu.reg.PC
+	ic.cpu.setSP(ic.cpu.reg.SP - 1)
+	ic.cpu.write(ic.cpu.reg.SP, bits.HiByte(pc))
+
+	// the interrupt is only picked after PCH is pushed, so if that push overwrote IE
+	// the interrupt can be cancelled, in which case the CPU ends up at 0x0000
+	intruptBit, pending := ic.highestPending()
+
+	ic.cpu.setSP(ic.cpu.reg.SP - 1)
+	ic.cpu.write(ic.cpu.reg.SP, bits.LoByte(pc))
+	ic.cpu.internalDelay()
+
+	if !pending {
+		ic.cpu.setPC(0x0000)
 		return
 	}
 
-	ic.cpu.exitHaltedState()
-	ic.cpu.clearIME()
 	ic.clearIFBit(intruptBit)
-	ic.cpu.pushStack(ic.cpu.reg.PC)
 
 	switch intruptBit {
 	case VBLANK_INTRUPT_BIT:
@@ -121,13 +119,24 @@ func (ic *IntruptController) serviceIntrupt(intruptBit uint8) {
 	}
 }
 
+// highestPending returns the enabled and requested interrupt with the highest priority
+func (ic *IntruptController) highestPending() (uint8, bool) {
+	for intruptBit := uint8(VBLANK_INTRUPT_BIT); intruptBit <= JOYPAD_INTRUPT_BIT; intruptBit++ {
+		if bits.IsSetInBoth(ic.intruptEnableReg, ic.intruptFlagReg, intruptBit) {
+			return intruptBit, true
+		}
+	}
+
+	return 0, false
+}
+
 func (ic *IntruptController) clearIFBit(pos uint8) {
 	ic.intruptFlagReg = bits.Reset(ic.intruptFlagReg, pos)
 }
 
 func (ic *IntruptController) requestIntrupt(intruptBit uint8) {
 	if !inRange(uint16(intruptBit), VBLANK_INTRUPT_BIT, JOYPAD_INTRUPT_BIT) {
-		log.Fatalf("Illegal interrupt requested of bit: %d", intruptBit)
+		raiseFault(fmt.Errorf("illegal interrupt requested of bit: %d", intruptBit))
 	}
 
 	ic.intruptFlagReg = bits.Set(ic.intruptFlagReg, intruptBit)
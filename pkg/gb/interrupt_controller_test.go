@@ -0,0 +1,104 @@
+package gb
+
+import "testing"
+
+// newTestIntruptController wires an interrupt controller to a CPU with only WRAM, HRAM and IE/IF on the bus,
+// counting the M-cycles the CPU spends
+func newTestIntruptController(mCycles *int) (*IntruptController, *CPU, *MMU) {
+	mmu := &MMU{}
+	cpu := &CPU{}
+	ic := &IntruptController{}
+
+	cpu.init(mmu, &DMAController{}, func(cTicks int) { *mCycles += cTicks / M_CYCLE_TICKS })
+	ic.init(mmu, cpu)
+
+	mmu.mapAddrSpace(ic)
+	mmu.mapAddrSpace(newWorkRAM())
+	mmu.mapAddrSpace(newHighRAM())
+
+	return ic, cpu, mmu
+}
+
+func TestIntruptDispatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		pc     uint16
+		sp     uint16
+		ie     uint8
+		ifReg  uint8
+		wantPC uint16
+		wantIF uint8
+		wantIE uint8
+	}{
+		{"timer", 0x1234, 0xD000, 0x04, 0x04, TIMER_INTRUPT_VEC, 0x00, 0x04},
+		{"VBlank before timer", 0x1234, 0xD000, 0x1F, 0x05, VBLANK_INTRUPT_VEC, 0x04, 0x1F},
+		{"joypad", 0x1234, 0xD000, 0x10, 0x1F, JOYPAD_INTRUPT_VEC, 0x0F, 0x10},
+		// PCH lands on IE and disables the timer interrupt before it is picked
+		{"cancelled by PCH push", 0x0212, 0x0000, 0x04, 0x04, 0x0000, 0x04, 0x02},
+		// PCH lands on IE and enables VBlank instead, which is picked over the timer
+		{"redirected by PCH push", 0x0112, 0x0000, 0x04, 0x05, VBLANK_INTRUPT_VEC, 0x04, 0x01},
+		// PCH lands on IE without changing what is picked
+		{"PCH push keeps IE", 0x0412, 0x0000, 0x04, 0x04, TIMER_INTRUPT_VEC, 0x00, 0x04},
+	}
+
+	for _, tt := range tests {
+		mCycles := 0
+		ic, cpu, mmu := newTestIntruptController(&mCycles)
+		cpu.reg.PC, cpu.reg.SP = tt.pc, tt.sp
+		cpu.IME = true
+		ic.write(IE_ADDR, tt.ie)
+		ic.write(IF_ADDR, tt.ifReg)
+
+		ic.handleIntrupts()
+
+		if cpu.reg.PC != tt.wantPC {
+			t.Errorf("%s: PC is 0x%04X, want 0x%04X", tt.name, cpu.reg.PC, tt.wantPC)
+		}
+
+		if ic.intruptFlagReg&^INTRUPT_MSK != tt.wantIF || ic.intruptEnableReg&^INTRUPT_MSK != tt.wantIE {
+			t.Errorf("%s: IF 0x%02X IE 0x%02X, want IF 0x%02X IE 0x%02X",
+				tt.name, ic.intruptFlagReg&^INTRUPT_MSK, ic.intruptEnableReg&^INTRUPT_MSK, tt.wantIF, tt.wantIE)
+		}
+
+		sp := tt.sp - 2
+		if cpu.reg.SP != sp || mmu.read(sp) != uint8(tt.pc) || mmu.read(sp+1)&^INTRUPT_MSK != uint8(tt.pc>>8)&^INTRUPT_MSK {
+			t.Errorf("%s: PC 0x%04X was not pushed below SP 0x%04X", tt.name, tt.pc, tt.sp)
+		}
+
+		if cpu.IME {
+			t.Errorf("%s: IME still set", tt.name)
+		}
+
+		if mCycles != 5 {
+			t.Errorf("%s: took %d M-cycles, want 5", tt.name, mCycles)
+		}
+	}
+}
+
+func TestIntruptNotDispatched(t *testing.T) {
+	tests := []struct {
+		name  string
+		ime   bool
+		ie    uint8
+		ifReg uint8
+	}{
+		{"IME clear", false, 0x04, 0x04},
+		{"not enabled", true, 0x01, 0x04},
+		{"not requested", true, 0x04, 0x01},
+	}
+
+	for _, tt := range tests {
+		mCycles := 0
+		ic, cpu, _ := newTestIntruptController(&mCycles)
+		cpu.reg.PC, cpu.reg.SP = 0x1234, 0xD000
+		cpu.IME = tt.ime
+		ic.write(IE_ADDR, tt.ie)
+		ic.write(IF_ADDR, tt.ifReg)
+
+		ic.handleIntrupts()
+
+		if cpu.reg.PC != 0x1234 || cpu.reg.SP != 0xD000 || mCycles != 0 {
+			t.Errorf("%s: dispatched to 0x%04X in %d M-cycles", tt.name, cpu.reg.PC, mCycles)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package gb
+
+// IORegisters is the register file for any I/O register that is not owned by an emulated component,
+// such as the APU registers. Unused bits, and registers that do not exist at all, read back as 1
+type IORegisters struct {
+	regs [IO_SIZE]uint8
+}
+
+const (
+	IO_SIZE = 0x80
+	IO_BASE = 0xFF00
+	IO_TOP  = 0xFF7F
+)
+
+// bits that always read back as 1, indexed by register address - IO_BASE
+var ioReadMasks = func() [IO_SIZE]uint8 {
+	masks := [IO_SIZE]uint8{}
+	for i := range masks {
+		masks[i] = 0xFF
+	}
+
+	audio := [...]uint8{
+		0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10 - NR14
+		0xFF, 0x3F, 0x00, 0xFF, 0xBF, // unused, NR21 - NR24
+		0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30 - NR34
+		0xFF, 0xFF, 0x00, 0x00, 0xBF, // unused, NR41 - NR44
+		0x00, 0x00, 0x70, // NR50 - NR52
+	}
+	copy(masks[0x10:], audio[:])
+
+	// wave RAM reads back as written
+	for i := 0x30; i < 0x40; i++ {
+		masks[i] = 0x00
+	}
+
+	return masks
+}()
+
+func newIORegisters() *IORegisters {
+	r := &IORegisters{}
+	r.init()
+
+	return r
+}
+
+func (r *IORegisters) init() {
+	r.regs = [IO_SIZE]uint8{}
+	r.regs[0xFF10-IO_BASE] = 0x80
+	r.regs[0xFF11-IO_BASE] = 0xBF
+	r.regs[0xFF12-IO_BASE] = 0xF3
+	r.regs[0xFF14-IO_BASE] = 0xBF
+	r.regs[0xFF16-IO_BASE] = 0x3F
+	r.regs[0xFF17-IO_BASE] = 0x00
+	r.regs[0xFF19-IO_BASE] = 0xBF
+	r.regs[0xFF1A-IO_BASE] = 0x7F
+	r.regs[0xFF1B-IO_BASE] = 0xFF
+	r.regs[0xFF1C-IO_BASE] = 0x9F
+	r.regs[0xFF1E-IO_BASE] = 0xBF
+	r.regs[0xFF20-IO_BASE] = 0xFF
+	r.regs[0xFF21-IO_BASE] = 0x00
+	r.regs[0xFF22-IO_BASE] = 0x00
+	r.regs[0xFF23-IO_BASE] = 0xBF
+	r.regs[0xFF24-IO_BASE] = 0x77
+	r.regs[0xFF25-IO_BASE] = 0xF3
+	r.regs[0xFF26-IO_BASE] = 0xF1
+}
+
+func (r *IORegisters) contains(addr uint16) bool {
+	return inRange(addr, IO_BASE, IO_TOP)
+}
+
+func (r *IORegisters) read(addr uint16) uint8 {
+	return r.regs[addr-IO_BASE] | ioReadMasks[addr-IO_BASE]
+}
+
+func (r *IORegisters) write(addr uint16, data uint8) {
+	r.regs[addr-IO_BASE] = data
+}
@@ -1,10 +1,6 @@
 package gb
 
-import (
-	"log"
-
-	"github.com/BeralaWoolies/GameboyGo/pkg/bits"
-)
+import "github.com/BeralaWoolies/GameboyGo/pkg/bits"
 
 type Joypad struct {
 	ic    *IntruptController
@@ -16,10 +12,12 @@ type Joypad struct {
 	left  *Button
 	start *Button
 	sel   *Button
-	reg   uint8
+	reg   uint8 // only the select bits are stored, the button lines are worked out on read
+	lines uint8 // P10 - P13 as of the last change, used to detect high to low transitions
 }
 
 type Button struct {
+	joyp    *Joypad
 	pressed uint8
 }
 
@@ -31,19 +29,24 @@ const (
 	JOYP_START_DOWN  = 3
 	JOYP_DPAD_SELECT = 4
 	JOYP_BTN_SELECT  = 5
+
+	JOYP_SELECT_MSK = 0x30
+	JOYP_LINES_MSK  = 0x0F
+	JOYP_UNUSED_MSK = 0xC0
 )
 
 func (joyp *Joypad) init(ic *IntruptController) {
 	joyp.ic = ic
-	joyp.a = &Button{}
-	joyp.b = &Button{}
-	joyp.up = &Button{}
-	joyp.down = &Button{}
-	joyp.right = &Button{}
-	joyp.left = &Button{}
-	joyp.start = &Button{}
-	joyp.sel = &Button{}
+	joyp.a = &Button{joyp: joyp, pressed: 1}
+	joyp.b = &Button{joyp: joyp, pressed: 1}
+	joyp.up = &Button{joyp: joyp, pressed: 1}
+	joyp.down = &Button{joyp: joyp, pressed: 1}
+	joyp.right = &Button{joyp: joyp, pressed: 1}
+	joyp.left = &Button{joyp: joyp, pressed: 1}
+	joyp.start = &Button{joyp: joyp, pressed: 1}
+	joyp.sel = &Button{joyp: joyp, pressed: 1}
 	joyp.reg = 0xFF
+	joyp.lines = JOYP_LINES_MSK
 }
 
 func (joyp *Joypad) contains(addr uint16) bool {
@@ -55,7 +58,7 @@ func (joyp *Joypad) read(addr uint16) uint8 {
 	case JOYP_ADDR:
 		return joyp.output()
 	default:
-		log.Fatalf("MMU mapped an illegal read address: 0x%02x to Joypad", addr)
+		busFault("Joypad", addr, false)
 		return 0xFF
 	}
 }
@@ -63,44 +66,75 @@ func (joyp *Joypad) read(addr uint16) uint8 {
 func (joyp *Joypad) write(addr uint16, data uint8) {
 	switch addr {
 	case JOYP_ADDR:
-		joyp.reg = ((data | 0xC0) & 0x30) | (joyp.reg & 0xF)
+		// changing the selection can pull a line low as well
+		joyp.reg = data & JOYP_SELECT_MSK
+		joyp.updateLines()
 	default:
-		log.Fatalf("MMU mapped an illegal write address: 0x%02x to Joypad", addr)
+		busFault("Joypad", addr, true)
 	}
 }
 
 func (joyp *Joypad) output() uint8 {
-	if bits.IsSet(joyp.reg, JOYP_DPAD_SELECT) && bits.IsSet(joyp.reg, JOYP_BTN_SELECT) {
-		return 0xFF
+	return JOYP_UNUSED_MSK | (joyp.reg & JOYP_SELECT_MSK) | joyp.buttonLines()
+}
+
+// buttonLines works out P10 - P13, a line is low if a button in any selected group is pressed.
+// With both groups selected the lines are shared between them
+func (joyp *Joypad) buttonLines() uint8 {
+	lines := uint8(JOYP_LINES_MSK)
+
+	if !bits.IsSet(joyp.reg, JOYP_DPAD_SELECT) {
+		lines &= joyp.right.pressed<<JOYP_A_RIGHT | joyp.left.pressed<<JOYP_B_LEFT |
+			joyp.up.pressed<<JOYP_SELECT_UP | joyp.down.pressed<<JOYP_START_DOWN
 	}
 
-	joyp.reg |= 0xCF
-
-	if !bits.IsSet(joyp.reg, JOYP_DPAD_SELECT) && !bits.IsSet(joyp.reg, JOYP_BTN_SELECT) {
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_A_RIGHT, joyp.a.eitherPressed(joyp.right))
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_B_LEFT, joyp.b.eitherPressed(joyp.left))
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_SELECT_UP, joyp.sel.eitherPressed(joyp.up))
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_START_DOWN, joyp.start.eitherPressed(joyp.down))
-	} else if bits.IsSet(joyp.reg, JOYP_BTN_SELECT) {
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_A_RIGHT, joyp.right.pressed)
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_B_LEFT, joyp.left.pressed)
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_SELECT_UP, joyp.up.pressed)
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_START_DOWN, joyp.down.pressed)
-	} else {
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_A_RIGHT, joyp.a.pressed)
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_B_LEFT, joyp.b.pressed)
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_SELECT_UP, joyp.sel.pressed)
-		joyp.reg = bits.SetCond(joyp.reg, JOYP_START_DOWN, joyp.start.pressed)
+	if !bits.IsSet(joyp.reg, JOYP_BTN_SELECT) {
+		lines &= joyp.a.pressed<<JOYP_A_RIGHT | joyp.b.pressed<<JOYP_B_LEFT |
+			joyp.sel.pressed<<JOYP_SELECT_UP | joyp.start.pressed<<JOYP_START_DOWN
 	}
 
-	return joyp.reg
+	return lines
 }
 
-func (btn *Button) eitherPressed(other *Button) uint8 {
-	return btn.pressed & other.pressed
+// updateLines requests the joypad interrupt when any of P10 - P13 goes from high to low
+func (joyp *Joypad) updateLines() {
+	lines := joyp.buttonLines()
+
+	if joyp.lines&^lines != 0 {
+		joyp.ic.requestIntrupt(JOYPAD_INTRUPT_BIT)
+	}
+
+	joyp.lines = lines
+}
+
+// selectedPressed reports whether a button in a currently selected group is held down
+func (joyp *Joypad) selectedPressed() bool {
+	return joyp.buttonLines() != JOYP_LINES_MSK
 }
 
 func (btn *Button) press(pressed bool) {
 	// pressed if 0
 	btn.pressed = bits.BoolToUint8(!pressed)
+	btn.joyp.updateLines()
+}
+
+// buttons returns every button in the bit order used by state and setState
+func (joyp *Joypad) buttons() [8]*Button {
+	return [8]*Button{joyp.a, joyp.b, joyp.sel, joyp.start, joyp.right, joyp.left, joyp.up, joyp.down}
+}
+
+// state packs the held buttons into a byte, a set bit means the button is held
+func (joyp *Joypad) state() uint8 {
+	state := uint8(0)
+	for i, btn := range joyp.buttons() {
+		state |= (btn.pressed ^ 1) << i
+	}
+
+	return state
+}
+
+func (joyp *Joypad) setState(state uint8) {
+	for i, btn := range joyp.buttons() {
+		btn.press(bits.IsSet(state, uint8(i)))
+	}
 }
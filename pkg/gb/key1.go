@@ -0,0 +1,62 @@
+package gb
+
+import "github.com/BeralaWoolies/GameboyGo/pkg/bits"
+
+// SpeedSwitch is the CGB KEY1 register. Arming it and then executing STOP switches the CPU between normal
+// and double speed. It is only mapped for carts that declare CGB support, otherwise 0xFF4D reads 0xFF like on a DMG
+type SpeedSwitch struct {
+	armed  bool
+	double bool
+}
+
+const (
+	KEY1_ADDR       = 0xFF4D
+	KEY1_ARMED_BIT  = 0
+	KEY1_SPEED_BIT  = 7
+	KEY1_UNUSED_MSK = 0x7E
+
+	// how long the CPU stays stopped while the clock switches over
+	SPEED_SWITCH_TICKS = 2050 * M_CYCLE_TICKS
+)
+
+func (s *SpeedSwitch) contains(addr uint16) bool {
+	return addr == KEY1_ADDR
+}
+
+func (s *SpeedSwitch) read(addr uint16) uint8 {
+	switch addr {
+	case KEY1_ADDR:
+		data := uint8(KEY1_UNUSED_MSK)
+		if s.armed {
+			data = bits.Set(data, KEY1_ARMED_BIT)
+		}
+		if s.double {
+			data = bits.Set(data, KEY1_SPEED_BIT)
+		}
+		return data
+	default:
+		busFault("KEY1", addr, false)
+		return 0xFF
+	}
+}
+
+func (s *SpeedSwitch) write(addr uint16, data uint8) {
+	switch addr {
+	case KEY1_ADDR:
+		// only the armed bit is writable
+		s.armed = bits.IsSet(data, KEY1_ARMED_BIT)
+	default:
+		busFault("KEY1", addr, true)
+	}
+}
+
+// trigger is called on STOP, reporting whether an armed switch went ahead
+func (s *SpeedSwitch) trigger() bool {
+	if !s.armed {
+		return false
+	}
+
+	s.armed = false
+	s.double = !s.double
+	return true
+}
@@ -0,0 +1,164 @@
+package gb
+
+import (
+	"fmt"
+
+	"github.com/BeralaWoolies/GameboyGo/pkg/bits"
+)
+
+// Macro plays back a sequence of joypad states when its hotkey is pressed
+type Macro struct {
+	name     string
+	hotkey   *InputBinding
+	steps    []MacroStep
+	held     bool
+	prevHeld bool
+}
+
+// MacroStep holds the buttons in state (packed like Joypad.state) down for a number of frames
+type MacroStep struct {
+	state  uint8
+	frames int
+}
+
+// MacroConfig is how a macro is stored in the input config, e.g.
+// {"name": "run", "hotkey": {"keys": ["R"], "gamepad": []}, "steps": [{"buttons": ["b", "left"], "frames": 30}]}
+type MacroConfig struct {
+	Name   string            `json:"name"`
+	Hotkey BindingConfig     `json:"hotkey"`
+	Steps  []MacroStepConfig `json:"steps"`
+}
+
+type MacroStepConfig struct {
+	Buttons []InputAction `json:"buttons"`
+	Frames  int           `json:"frames"`
+}
+
+// MacroPlayer steps through the running macro, one frame per call to next
+type MacroPlayer struct {
+	macro *Macro
+	step  int
+	frame int
+}
+
+// Turbo presses a button on and off every rate frames for as long as its turbo binding is held
+type Turbo struct {
+	rate   int
+	frames map[InputAction]int
+}
+
+const DEFAULT_TURBO_RATE = 2
+
+var turboRates = []int{1, 2, 3, 4, 6, 8}
+
+// bit of each joypad action in a state packed by Joypad.state
+var joypadActionBits = map[InputAction]uint8{
+	ACTION_A:      0,
+	ACTION_B:      1,
+	ACTION_SELECT: 2,
+	ACTION_START:  3,
+	ACTION_RIGHT:  4,
+	ACTION_LEFT:   5,
+	ACTION_UP:     6,
+	ACTION_DOWN:   7,
+}
+
+// the button each turbo action drives
+var turboActions = map[InputAction]InputAction{
+	ACTION_A: ACTION_TURBO_A,
+	ACTION_B: ACTION_TURBO_B,
+}
+
+func parseMacro(mc MacroConfig) *Macro {
+	m := &Macro{name: mc.Name, hotkey: parseBinding("macro "+mc.Name, mc.Hotkey)}
+
+	for _, sc := range mc.Steps {
+		step := MacroStep{frames: sc.Frames}
+		if step.frames < 1 {
+			fmt.Printf("Ignoring step of macro %s that lasts %d frames\n", mc.Name, sc.Frames)
+			continue
+		}
+
+		for _, btn := range sc.Buttons {
+			bit, ok := joypadActionBits[btn]
+			if !ok {
+				fmt.Printf("Ignoring unknown button %q in macro %s\n", btn, mc.Name)
+				continue
+			}
+
+			step.state = bits.Set(step.state, bit)
+		}
+
+		m.steps = append(m.steps, step)
+	}
+
+	return m
+}
+
+func (m *Macro) triggered() bool {
+	return m.held && !m.prevHeld
+}
+
+// start runs m from the beginning, replacing whichever macro was running
+func (mp *MacroPlayer) start(m *Macro) {
+	if len(m.steps) == 0 {
+		return
+	}
+
+	mp.macro = m
+	mp.step = 0
+	mp.frame = 0
+	fmt.Printf("Playing macro %s\n", m.name)
+}
+
+// next returns the joypad state for this frame, or 0 when no macro is running
+func (mp *MacroPlayer) next() uint8 {
+	if mp.macro == nil {
+		return 0
+	}
+
+	step := mp.macro.steps[mp.step]
+	mp.frame++
+
+	if mp.frame >= step.frames {
+		mp.frame = 0
+		mp.step++
+
+		if mp.step >= len(mp.macro.steps) {
+			mp.macro = nil
+		}
+	}
+
+	return step.state
+}
+
+func newTurbo(rate int) *Turbo {
+	return &Turbo{rate: rate, frames: make(map[InputAction]int)}
+}
+
+// update returns whether the button driven by the turbo action is down this frame
+func (t *Turbo) update(action InputAction, held bool) bool {
+	if !held {
+		t.frames[action] = 0
+		return false
+	}
+
+	down := (t.frames[action]/t.rate)%2 == 0
+	t.frames[action]++
+
+	return down
+}
+
+// cycleRate moves on to the next turbo rate, wrapping around to the fastest
+func (t *Turbo) cycleRate() {
+	next := turboRates[0]
+	for _, rate := range turboRates {
+		if rate > t.rate {
+			next = rate
+			break
+		}
+	}
+
+	t.rate = next
+	fmt.Printf("Turbo rate: toggling every %d frame(s)\n", t.rate)
+}
@@ -2,7 +2,6 @@ package gb
 
 import (
 	"fmt"
-	"log"
 	"math"
 	"strconv"
 
@@ -61,7 +60,7 @@ func (mbc *MBC1) read(addr uint16) uint8 {
 			return mbc.cart.rom[((((mbc.ramBankNum<<5)|mbc.romLo)&mbc.romBankMask)*0x4000)+uint32(addr-0x4000)]
 		}
 	} else if inRange(addr, EXT_RAM_BASE, EXT_RAM_TOP) {
-		if !mbc.ramEnabled {
+		if !mbc.ramEnabled || !mbc.cart.hasRam {
 			return 0xFF
 		}
 
@@ -73,7 +72,7 @@ func (mbc *MBC1) read(addr uint16) uint8 {
 		return mbc.cart.ram[(uint32(bank)*0x2000+uint32(addr-EXT_RAM_BASE))%mbc.cart.ramSize]
 	}
 
-	log.Fatalf("MMU mapped an illegal read address: 0x%02x to MBC1", addr)
+	busFault("MBC1", addr, false)
 	return 0xFF
 }
 
@@ -108,7 +107,7 @@ func (mbc *MBC1) write(addr uint16, data uint8) {
 			return
 		}
 	} else if inRange(addr, EXT_RAM_BASE, EXT_RAM_TOP) {
-		if !mbc.ramEnabled {
+		if !mbc.ramEnabled || !mbc.cart.hasRam {
 			return
 		}
 
@@ -121,7 +120,27 @@ func (mbc *MBC1) write(addr uint16, data uint8) {
 		return
 	}
 
-	log.Fatalf("MMU mapped an illegal write address: 0x%02x to MBC1", addr)
+	busFault("MBC1", addr, true)
+}
+
+func (mbc *MBC1) romBank(addr uint16) uint32 {
+	if inRange(addr, ROM_BASE, 0x3FFF) {
+		if mbc.bigROM() && mbc.mode == MODE1 {
+			return (mbc.ramBankNum << 5) & mbc.romBankMask
+		}
+
+		return 0
+	}
+
+	return ((mbc.ramBankNum << 5) | mbc.romLo) & mbc.romBankMask
+}
+
+func (mbc *MBC1) ramBank() uint32 {
+	if mbc.bigRam() && mbc.mode == MODE1 {
+		return mbc.ramBankNum
+	}
+
+	return 0
 }
 
 func (mbc *MBC1) bigROM() bool {
@@ -2,7 +2,6 @@ package gb
 
 import (
 	"fmt"
-	"log"
 	"math"
 	"strconv"
 
@@ -68,7 +67,7 @@ func (mbc *MBC3) read(addr uint16) uint8 {
 		}
 	} else if inRange(addr, EXT_RAM_BASE, EXT_RAM_TOP) {
 		if mbc.mode == RAM_SELECT {
-			if !mbc.ramEnabled {
+			if !mbc.ramEnabled || !mbc.cart.hasRam {
 				return 0xFF
 			}
 
@@ -87,7 +86,7 @@ func (mbc *MBC3) read(addr uint16) uint8 {
 		}
 	}
 
-	log.Fatalf("MMU mapped an illegal read address: 0x%02x to MBC3", addr)
+	busFault("MBC3", addr, false)
 	return 0xFF
 }
 
@@ -134,7 +133,7 @@ func (mbc *MBC3) write(addr uint16, data uint8) {
 		}
 	} else if inRange(addr, EXT_RAM_BASE, EXT_RAM_TOP) {
 		if mbc.mode == RAM_SELECT {
-			if !mbc.ramEnabled {
+			if !mbc.ramEnabled || !mbc.cart.hasRam {
 				return
 			}
 
@@ -155,7 +154,23 @@ func (mbc *MBC3) write(addr uint16, data uint8) {
 		}
 	}
 
-	log.Fatalf("MMU mapped an illegal write address: 0x%02x to MBC3", addr)
+	busFault("MBC3", addr, true)
+}
+
+func (mbc *MBC3) romBank(addr uint16) uint32 {
+	if inRange(addr, ROM_BASE, 0x3FFF) {
+		return 0
+	}
+
+	return mbc.romLo & mbc.romBankMask
+}
+
+func (mbc *MBC3) ramBank() uint32 {
+	if mbc.bigRam() {
+		return mbc.ramBankNum
+	}
+
+	return 0
 }
 
 func (mbc *MBC3) bigRam() bool {
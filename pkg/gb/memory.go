@@ -0,0 +1,82 @@
+package gb
+
+// WorkRAM is the internal 8 KiB of work RAM, which also shows up again at 0xE000 - 0xFDFF (echo RAM)
+type WorkRAM struct {
+	memory [WRAM_SIZE]uint8
+}
+
+// HighRAM is the 127 bytes of RAM just below IE
+type HighRAM struct {
+	memory [HRAM_SIZE]uint8
+}
+
+// UnusableRegion covers 0xFEA0 - 0xFEFF, which ignores writes and reads back 0x00, or 0xFF while OAM is locked
+type UnusableRegion struct {
+	ppu *PPU
+}
+
+const (
+	WRAM_SIZE = 0x2000
+	WRAM_BASE = 0xC000
+	WRAM_TOP  = 0xDFFF
+
+	ECHO_RAM_BASE = 0xE000
+	ECHO_RAM_TOP  = 0xFDFF
+
+	UNUSABLE_BASE = 0xFEA0
+	UNUSABLE_TOP  = 0xFEFF
+
+	HRAM_SIZE = 0x7F
+	HRAM_BASE = 0xFF80
+	HRAM_TOP  = 0xFFFE
+)
+
+func newWorkRAM() *WorkRAM {
+	return &WorkRAM{}
+}
+
+func (r *WorkRAM) contains(addr uint16) bool {
+	return inRange(addr, WRAM_BASE, ECHO_RAM_TOP)
+}
+
+func (r *WorkRAM) read(addr uint16) uint8 {
+	return r.memory[(addr-WRAM_BASE)%WRAM_SIZE]
+}
+
+func (r *WorkRAM) write(addr uint16, data uint8) {
+	r.memory[(addr-WRAM_BASE)%WRAM_SIZE] = data
+}
+
+func newHighRAM() *HighRAM {
+	return &HighRAM{}
+}
+
+func (r *HighRAM) contains(addr uint16) bool {
+	return inRange(addr, HRAM_BASE, HRAM_TOP)
+}
+
+func (r *HighRAM) read(addr uint16) uint8 {
+	return r.memory[addr-HRAM_BASE]
+}
+
+func (r *HighRAM) write(addr uint16, data uint8) {
+	r.memory[addr-HRAM_BASE] = data
+}
+
+func newUnusableRegion(ppu *PPU) *UnusableRegion {
+	return &UnusableRegion{ppu: ppu}
+}
+
+func (u *UnusableRegion) contains(addr uint16) bool {
+	return inRange(addr, UNUSABLE_BASE, UNUSABLE_TOP)
+}
+
+func (u *UnusableRegion) read(addr uint16) uint8 {
+	if u.ppu.oamLocked() {
+		return 0xFF
+	}
+
+	return 0x00
+}
+
+func (u *UnusableRegion) write(addr uint16, data uint8) {}
@@ -0,0 +1,458 @@
+package gb
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// MemoryViewer is a hex editor over the full 64 KiB bus as seen by the MMU
+type MemoryViewer struct {
+	mmu    *MMU
+	cart   *Cart
+	ppu    *PPU
+	buffer *ebiten.Image
+	x      int
+	y      int
+
+	base       uint16 // address of the first visible row
+	cursor     uint16
+	romBankSel int // -1 follows whatever bank the MBC currently has mapped
+	ramBankSel int
+
+	focused     bool
+	editPending bool
+	editVal     uint8
+
+	prompt   ViewerPrompt
+	input    []rune
+	search   []uint8
+	matchLen int
+	status   string
+
+	frame       uint32
+	writeFrames [0x10000]uint32
+}
+
+type ViewerPrompt uint8
+
+const (
+	MEM_VIEWER_SCREEN_WIDTH  = 56 * DBG_CHAR_WIDTH
+	MEM_VIEWER_SCREEN_HEIGHT = 20 * DBG_CHAR_HEIGHT
+
+	MEM_VIEWER_ROWS       = 16
+	MEM_VIEWER_BYTES      = 16
+	MEM_VIEWER_FIRST_ROW  = 2
+	MEM_VIEWER_FIRST_COL  = 6
+	MEM_VIEWER_STATUS_ROW = MEM_VIEWER_FIRST_ROW + MEM_VIEWER_ROWS
+	MEM_VIEWER_PAGE       = MEM_VIEWER_ROWS * MEM_VIEWER_BYTES
+
+	WRITE_HIGHLIGHT_FRAMES = 60
+	MEM_SPACE_SIZE         = 0x10000
+
+	NO_PROMPT     ViewerPrompt = 0
+	GOTO_PROMPT   ViewerPrompt = 1
+	SEARCH_PROMPT ViewerPrompt = 2
+)
+
+func newMemoryViewer(mmu *MMU, cart *Cart, ppu *PPU, x int, y int) *MemoryViewer {
+	mv := &MemoryViewer{
+		mmu:        mmu,
+		cart:       cart,
+		ppu:        ppu,
+		buffer:     ebiten.NewImage(MEM_VIEWER_SCREEN_WIDTH, MEM_VIEWER_SCREEN_HEIGHT),
+		x:          x,
+		y:          y,
+		romBankSel: -1,
+		ramBankSel: -1,
+		frame:      1,
+	}
+
+	return mv
+}
+
+func (mv *MemoryViewer) recordWrite(addr uint16) {
+	mv.writeFrames[addr] = mv.frame
+}
+
+func (mv *MemoryViewer) peek(addr uint16) uint8 {
+	if off, ok := mv.bankedROMOffset(addr); ok && mv.romBankSel >= 0 && addr >= ROM_BANK_SIZE {
+		return mv.cart.rom[off]
+	}
+
+	if off, ok := mv.bankedRAMOffset(addr); ok && mv.ramBankSel >= 0 {
+		return mv.cart.ram[off]
+	}
+
+	// the debugger is not subject to the PPU access locks
+	if p := mv.ppu.memPtr(addr); p != nil {
+		return *p
+	}
+
+	return mv.mmu.read(addr)
+}
+
+func (mv *MemoryViewer) poke(addr uint16, data uint8) {
+	// writes through the bus would hit the MBC registers, so patch the ROM image directly
+	if mv.mmu.addrSpace(addr) == Addressable(mv.cart) {
+		if off, ok := mv.bankedROMOffset(addr); ok {
+			mv.cart.rom[off] = data
+			mv.recordWrite(addr)
+			return
+		}
+
+		if off, ok := mv.bankedRAMOffset(addr); ok && mv.ramBankSel >= 0 {
+			mv.cart.ram[off] = data
+			mv.recordWrite(addr)
+			return
+		}
+	}
+
+	if p := mv.ppu.memPtr(addr); p != nil {
+		*p = data
+		mv.recordWrite(addr)
+		return
+	}
+
+	mv.mmu.write(addr, data)
+}
+
+func (mv *MemoryViewer) bankedROMOffset(addr uint16) (int, bool) {
+	if !inRange(addr, ROM_BASE, ROM_TOP) {
+		return 0, false
+	}
+
+	bank := int(mv.cart.romBank(addr))
+	if mv.romBankSel >= 0 && addr >= ROM_BANK_SIZE {
+		bank = mv.romBankSel
+	}
+
+	off := bank*ROM_BANK_SIZE + int(addr%ROM_BANK_SIZE)
+	return off, off < len(mv.cart.rom)
+}
+
+func (mv *MemoryViewer) bankedRAMOffset(addr uint16) (int, bool) {
+	if !inRange(addr, EXT_RAM_BASE, EXT_RAM_TOP) {
+		return 0, false
+	}
+
+	bank := int(mv.cart.ramBank())
+	if mv.ramBankSel >= 0 {
+		bank = mv.ramBankSel
+	}
+
+	off := bank*RAM_BANK_SIZE + int(addr-EXT_RAM_BASE)
+	return off, off < len(mv.cart.ram)
+}
+
+func (mv *MemoryViewer) handleInput() {
+	mv.frame++
+
+	cx, cy, inside := cursorIn(mv.x, mv.y, MEM_VIEWER_SCREEN_WIDTH, MEM_VIEWER_SCREEN_HEIGHT)
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		mv.focused = inside
+		if inside {
+			mv.selectAt(cx, cy)
+		}
+	}
+
+	if inside {
+		if _, dy := ebiten.Wheel(); dy != 0 {
+			mv.base = (mv.base - uint16(int(dy)*MEM_VIEWER_BYTES)) &^ 0xF
+		}
+	}
+
+	if !mv.focused {
+		return
+	}
+
+	if mv.prompt != NO_PROMPT {
+		mv.handlePrompt()
+		return
+	}
+
+	mv.handleNavigation()
+
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if digit, ok := hexDigit(r); ok {
+			mv.edit(digit)
+			continue
+		}
+
+		switch r {
+		case '[':
+			mv.switchBank(-1)
+		case ']':
+			mv.switchBank(1)
+		case '\\':
+			mv.romBankSel = -1
+			mv.ramBankSel = -1
+			mv.status = "following bus banks"
+		case '/':
+			mv.startPrompt(SEARCH_PROMPT)
+		case 'g', 'G':
+			mv.startPrompt(GOTO_PROMPT)
+		case 'n', 'N':
+			mv.findNext()
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		if mv.editPending {
+			mv.editPending = false
+		} else {
+			mv.focused = false
+		}
+	}
+}
+
+func (mv *MemoryViewer) handleNavigation() {
+	moved := true
+
+	switch {
+	case keyRepeated(ebiten.KeyArrowLeft):
+		mv.cursor--
+	case keyRepeated(ebiten.KeyArrowRight):
+		mv.cursor++
+	case keyRepeated(ebiten.KeyArrowUp):
+		mv.cursor -= MEM_VIEWER_BYTES
+	case keyRepeated(ebiten.KeyArrowDown):
+		mv.cursor += MEM_VIEWER_BYTES
+	case keyRepeated(ebiten.KeyPageUp):
+		mv.cursor -= MEM_VIEWER_PAGE
+	case keyRepeated(ebiten.KeyPageDown):
+		mv.cursor += MEM_VIEWER_PAGE
+	default:
+		moved = false
+	}
+
+	if moved {
+		mv.editPending = false
+		mv.scrollToCursor()
+	}
+}
+
+func (mv *MemoryViewer) selectAt(px int, py int) {
+	row := py/DBG_CHAR_HEIGHT - MEM_VIEWER_FIRST_ROW
+	col := px/DBG_CHAR_WIDTH - MEM_VIEWER_FIRST_COL
+
+	if row < 0 || row >= MEM_VIEWER_ROWS || col < 0 || col/3 >= MEM_VIEWER_BYTES {
+		return
+	}
+
+	mv.cursor = mv.base + uint16(row*MEM_VIEWER_BYTES+col/3)
+	mv.editPending = false
+}
+
+func (mv *MemoryViewer) scrollToCursor() {
+	if mv.cursor-mv.base >= MEM_VIEWER_PAGE {
+		if mv.cursor < mv.base {
+			mv.base = mv.cursor &^ 0xF
+		} else {
+			mv.base = (mv.cursor &^ 0xF) - (MEM_VIEWER_PAGE - MEM_VIEWER_BYTES)
+		}
+	}
+}
+
+func (mv *MemoryViewer) edit(digit uint8) {
+	if !mv.editPending {
+		mv.editPending = true
+		mv.editVal = digit << 4
+		return
+	}
+
+	mv.poke(mv.cursor, mv.editVal|digit)
+	mv.editPending = false
+	mv.status = fmt.Sprintf("wrote 0x%02X to 0x%04X", mv.editVal|digit, mv.cursor)
+	mv.cursor++
+	mv.scrollToCursor()
+}
+
+func (mv *MemoryViewer) switchBank(delta int) {
+	if inRange(mv.cursor, ROM_BANK_SIZE, ROM_TOP) {
+		if mv.romBankSel < 0 {
+			mv.romBankSel = int(mv.cart.romBank(mv.cursor))
+		}
+
+		n := mv.cart.numROMBanks()
+		mv.romBankSel = (mv.romBankSel + delta + n) % n
+		mv.status = fmt.Sprintf("viewing ROM bank 0x%02X", mv.romBankSel)
+	} else if inRange(mv.cursor, EXT_RAM_BASE, EXT_RAM_TOP) && mv.cart.numRAMBanks() > 0 {
+		if mv.ramBankSel < 0 {
+			mv.ramBankSel = int(mv.cart.ramBank())
+		}
+
+		n := mv.cart.numRAMBanks()
+		mv.ramBankSel = (mv.ramBankSel + delta + n) % n
+		mv.status = fmt.Sprintf("viewing RAM bank 0x%02X", mv.ramBankSel)
+	} else {
+		mv.status = "no switchable bank at cursor"
+	}
+}
+
+func (mv *MemoryViewer) startPrompt(prompt ViewerPrompt) {
+	mv.prompt = prompt
+	mv.input = mv.input[:0]
+	mv.editPending = false
+}
+
+func (mv *MemoryViewer) handlePrompt() {
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if _, ok := hexDigit(r); ok || (r == ' ' && mv.prompt == SEARCH_PROMPT) {
+			mv.input = append(mv.input, r)
+		}
+	}
+
+	if keyRepeated(ebiten.KeyBackspace) && len(mv.input) > 0 {
+		mv.input = mv.input[:len(mv.input)-1]
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		mv.prompt = NO_PROMPT
+		return
+	}
+
+	if !inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		return
+	}
+
+	prompt := mv.prompt
+	mv.prompt = NO_PROMPT
+
+	switch prompt {
+	case GOTO_PROMPT:
+		addr, err := strconv.ParseUint(string(mv.input), 16, 16)
+		if err != nil {
+			mv.status = "invalid address"
+			return
+		}
+
+		mv.cursor = uint16(addr)
+		mv.base = mv.cursor &^ 0xF
+	case SEARCH_PROMPT:
+		hex := strings.ReplaceAll(string(mv.input), " ", "")
+		if len(hex) == 0 {
+			mv.status = "empty search"
+			return
+		}
+
+		if len(hex)%2 == 1 {
+			hex = "0" + hex
+		}
+
+		mv.search = mv.search[:0]
+		for i := 0; i < len(hex); i += 2 {
+			val, _ := strconv.ParseUint(hex[i:i+2], 16, 8)
+			mv.search = append(mv.search, uint8(val))
+		}
+
+		mv.findNext()
+	}
+}
+
+func (mv *MemoryViewer) findNext() {
+	if len(mv.search) == 0 {
+		mv.status = "nothing to search for"
+		return
+	}
+
+	// search a snapshot rather than going through the bus for every compare. It is rotated to start after
+	// the cursor, with the start repeated at the end so matches can wrap past 0xFFFF
+	mem := mv.snapshot()
+	start := (int(mv.cursor) + 1) % MEM_SPACE_SIZE
+	hay := append(mem[start:], mem[:start]...)
+	hay = append(hay, hay[:min(len(mv.search)-1, len(hay))]...)
+
+	if idx := bytes.Index(hay, mv.search); idx >= 0 {
+		addr := uint16(start + idx)
+		mv.cursor = addr
+		mv.matchLen = len(mv.search)
+		mv.scrollToCursor()
+		mv.status = fmt.Sprintf("found at 0x%04X", addr)
+		return
+	}
+
+	mv.matchLen = 0
+	mv.status = fmt.Sprintf("% X not found", mv.search)
+}
+
+// snapshot reads the whole address space as the viewer shows it
+func (mv *MemoryViewer) snapshot() []byte {
+	mem := make([]byte, MEM_SPACE_SIZE)
+	for addr := range mem {
+		mem[addr] = mv.peek(uint16(addr))
+	}
+
+	return mem
+}
+
+func (mv *MemoryViewer) bankLabel(sel int, busBank uint32) string {
+	if sel < 0 {
+		return fmt.Sprintf("%02X(bus)", busBank)
+	}
+
+	return fmt.Sprintf("%02X", sel)
+}
+
+func (mv *MemoryViewer) draw(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	mv.buffer.Fill(dbgBackground)
+
+	dbgPrint(mv.buffer, fmt.Sprintf("MEMORY %04X  ROMX:%s  SRAM:%s", mv.cursor,
+		mv.bankLabel(mv.romBankSel, mv.cart.romBank(ROM_BANK_SIZE)),
+		mv.bankLabel(mv.ramBankSel, mv.cart.ramBank())), 0, 0)
+	dbgPrint(mv.buffer, "      00 01 02 03 04 05 06 07 08 09 0A 0B 0C 0D 0E 0F", 0, 1)
+
+	var line strings.Builder
+	for row := 0; row < MEM_VIEWER_ROWS; row++ {
+		rowAddr := mv.base + uint16(row*MEM_VIEWER_BYTES)
+
+		line.Reset()
+		fmt.Fprintf(&line, "%04X:", rowAddr)
+
+		for col := 0; col < MEM_VIEWER_BYTES; col++ {
+			addr := rowAddr + uint16(col)
+			cellCol := MEM_VIEWER_FIRST_COL + col*3
+			cellRow := MEM_VIEWER_FIRST_ROW + row
+
+			if addr == mv.cursor {
+				dbgFillCell(mv.buffer, cellCol, cellRow, 2, dbgCursorColor)
+			} else if addr-mv.cursor < uint16(mv.matchLen) {
+				dbgFillCell(mv.buffer, cellCol, cellRow, 2, dbgMatchColor)
+			} else if written := mv.writeFrames[addr]; written != 0 && mv.frame-written < WRITE_HIGHLIGHT_FRAMES {
+				age := mv.frame - written
+				alpha := uint8(0xFF * (WRITE_HIGHLIGHT_FRAMES - age) / WRITE_HIGHLIGHT_FRAMES)
+				dbgFillCell(mv.buffer, cellCol, cellRow, 2, fadeColor(dbgHighlight, alpha))
+			}
+
+			if addr == mv.cursor && mv.editPending {
+				fmt.Fprintf(&line, " %X_", mv.editVal>>4)
+			} else {
+				fmt.Fprintf(&line, " %02X", mv.peek(addr))
+			}
+		}
+
+		dbgPrint(mv.buffer, line.String(), 0, MEM_VIEWER_FIRST_ROW+row)
+	}
+
+	switch mv.prompt {
+	case GOTO_PROMPT:
+		dbgPrint(mv.buffer, "goto: "+string(mv.input)+"_", 0, MEM_VIEWER_STATUS_ROW)
+	case SEARCH_PROMPT:
+		dbgPrint(mv.buffer, "search hex: "+string(mv.input)+"_", 0, MEM_VIEWER_STATUS_ROW)
+	default:
+		dbgPrint(mv.buffer, mv.status, 0, MEM_VIEWER_STATUS_ROW)
+	}
+
+	dbgPrint(mv.buffer, "0-F edit [ ] bank \\ bus / find N next G goto", 0, MEM_VIEWER_STATUS_ROW+1)
+
+	if mv.focused {
+		vector.StrokeRect(mv.buffer, 0, 0, MEM_VIEWER_SCREEN_WIDTH, MEM_VIEWER_SCREEN_HEIGHT, 1, dbgCursorColor, false)
+	}
+
+	screen.DrawImage(mv.buffer, opt)
+}
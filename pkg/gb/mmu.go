@@ -2,7 +2,6 @@ package gb
 
 import (
 	"fmt"
-	"log"
 	"slices"
 )
 
@@ -14,6 +13,7 @@ type Addressable interface {
 
 type MMU struct {
 	addrSpaces []Addressable
+	onWrite    func(addr uint16) // optional hook used by the debugger to track writes
 }
 
 func (mmu *MMU) mapAddrSpace(addrSpace Addressable) {
@@ -42,17 +42,20 @@ func (mmu *MMU) read(addr uint16) uint8 {
 		return space.read(addr)
 	}
 
-	log.Fatalf("MMU has no mapping read address: 0x%02x", addr)
+	busFault("", addr, false)
 	return 0xFF
 }
 
 func (mmu *MMU) write(addr uint16, data uint8) {
 	if space := mmu.addrSpace(addr); space != nil {
 		space.write(addr, data)
+		if mmu.onWrite != nil {
+			mmu.onWrite(addr)
+		}
 		return
 	}
 
-	log.Fatalf("MMU has no mapping write address: 0x%02x", addr)
+	busFault("", addr, true)
 }
 
 func inRange(addr uint16, base uint16, top uint16) bool {
@@ -0,0 +1,273 @@
+package gb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+)
+
+// Movie records or replays the joypad state of every frame. The file starts with a header identifying
+// the ROM, boot ROM and initial save RAM, followed by one byte of joypad state per frame with a hash
+// of the emulator state after every MOVIE_HASH_INTERVAL frames so playback can detect a desync
+type Movie struct {
+	file      *os.File
+	w         *bufio.Writer
+	r         *bufio.Reader
+	filename  string
+	recording bool
+	finished  bool
+	frame     uint32
+}
+
+type movieHeader struct {
+	Magic       [4]byte
+	Version     uint8
+	ROMHash     [sha1.Size]byte
+	HasBootROM  uint8
+	BootROMHash [sha1.Size]byte
+	SaveRAMSize uint32
+	CheatsSize  uint32 // length of the enabled cheat codes stored after the save RAM, one per line
+}
+
+type MovieDesyncError struct {
+	Frame    uint32
+	Expected uint64
+	Actual   uint64
+}
+
+const (
+	MOVIE_VERSION       = 2
+	MOVIE_HASH_INTERVAL = 60
+	MAX_MOVIE_CHEATS    = 0x10000
+)
+
+var movieMagic = [4]byte{'G', 'B', 'M', 0x1A}
+
+func (e *MovieDesyncError) Error() string {
+	return fmt.Sprintf("movie desynced at frame %d (state hash 0x%016x, expected 0x%016x)", e.Frame, e.Actual, e.Expected)
+}
+
+func newMovieHeader(cart *Cart, bootRom *BootRom, cheats string) movieHeader {
+	header := movieHeader{
+		Magic:       movieMagic,
+		Version:     MOVIE_VERSION,
+		ROMHash:     sha1.Sum(cart.rom),
+		SaveRAMSize: uint32(len(cart.ram)),
+		CheatsSize:  uint32(len(cheats)),
+	}
+
+	if bootRom != nil {
+		header.HasBootROM = 1
+		header.BootROMHash = sha1.Sum(bootRom.rom)
+	}
+
+	return header
+}
+
+// newMovieRecorder starts recording to filename, the current cart RAM is stored as the initial save RAM
+// along with the cheat codes in effect
+func newMovieRecorder(filename string, cart *Cart, bootRom *BootRom, cheats []string) (*Movie, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Movie{file: file, w: bufio.NewWriter(file), filename: filename, recording: true}
+
+	codes := strings.Join(cheats, "\n")
+	header := newMovieHeader(cart, bootRom, codes)
+	if err := binary.Write(m.w, binary.LittleEndian, &header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err := m.w.Write(cart.ram); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err := m.w.WriteString(codes); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	fmt.Printf("Recording movie to %s\n", filename)
+	return m, nil
+}
+
+// newMoviePlayer opens filename for playback, refusing to play it against a different ROM, boot ROM or cheats.
+// The cart RAM is replaced by the save RAM stored in the movie so the real save file is left alone
+func newMoviePlayer(filename string, cart *Cart, bootRom *BootRom, cheats []string) (*Movie, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Movie{file: file, r: bufio.NewReader(file), filename: filename}
+	if err := m.readHeader(cart, bootRom, strings.Join(cheats, "\n")); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	fmt.Printf("Playing movie from %s\n", filename)
+	return m, nil
+}
+
+func (m *Movie) readHeader(cart *Cart, bootRom *BootRom, cheats string) error {
+	var header movieHeader
+	if err := binary.Read(m.r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("invalid movie %s: %w", m.filename, err)
+	}
+
+	if header.Magic != movieMagic || header.Version != MOVIE_VERSION {
+		return fmt.Errorf("invalid movie %s: unsupported format", m.filename)
+	}
+
+	expected := newMovieHeader(cart, bootRom, cheats)
+	if header.ROMHash != expected.ROMHash {
+		return fmt.Errorf("movie %s was recorded with a different ROM (SHA-1 %x)", m.filename, header.ROMHash)
+	}
+
+	if header.HasBootROM != expected.HasBootROM {
+		if header.HasBootROM != 0 {
+			return fmt.Errorf("movie %s was recorded with a boot ROM, specify it with -bootrom", m.filename)
+		}
+		return fmt.Errorf("movie %s was recorded without a boot ROM", m.filename)
+	}
+
+	if header.BootROMHash != expected.BootROMHash {
+		return fmt.Errorf("movie %s was recorded with a different boot ROM (SHA-1 %x)", m.filename, header.BootROMHash)
+	}
+
+	if header.SaveRAMSize != expected.SaveRAMSize {
+		return fmt.Errorf("movie %s has %d bytes of save RAM, but the cart has %d",
+			m.filename, header.SaveRAMSize, expected.SaveRAMSize)
+	}
+
+	if header.CheatsSize > MAX_MOVIE_CHEATS {
+		return fmt.Errorf("invalid movie %s: %d bytes of cheat codes", m.filename, header.CheatsSize)
+	}
+
+	sram := make([]byte, header.SaveRAMSize)
+	if _, err := io.ReadFull(m.r, sram); err != nil {
+		return fmt.Errorf("invalid movie %s: %w", m.filename, err)
+	}
+
+	recorded := make([]byte, header.CheatsSize)
+	if _, err := io.ReadFull(m.r, recorded); err != nil {
+		return fmt.Errorf("invalid movie %s: %w", m.filename, err)
+	}
+
+	if string(recorded) != cheats {
+		return fmt.Errorf("movie %s was recorded with different cheats enabled: [%s]",
+			m.filename, strings.ReplaceAll(string(recorded), "\n", ", "))
+	}
+
+	return cart.replaceRAM(sram)
+}
+
+func (m *Movie) playing() bool {
+	return !m.recording && !m.finished
+}
+
+// input records the joypad state for the coming frame, or drives the joypad from the movie on playback
+func (m *Movie) input(joyp *Joypad) error {
+	if m.finished {
+		return nil
+	}
+
+	if m.recording {
+		return m.w.WriteByte(joyp.state())
+	}
+
+	state, err := m.r.ReadByte()
+	if err != nil {
+		m.finish()
+		return nil
+	}
+
+	joyp.setState(state)
+	return nil
+}
+
+// endFrame is called after every emulated frame, stateHash is only called on frames that carry a hash
+func (m *Movie) endFrame(stateHash func() uint64) error {
+	if m.finished {
+		return nil
+	}
+
+	m.frame++
+	if m.frame%MOVIE_HASH_INTERVAL != 0 {
+		return nil
+	}
+
+	if m.recording {
+		return binary.Write(m.w, binary.LittleEndian, stateHash())
+	}
+
+	var expected uint64
+	if err := binary.Read(m.r, binary.LittleEndian, &expected); err != nil {
+		m.finish()
+		return nil
+	}
+
+	if actual := stateHash(); actual != expected {
+		return &MovieDesyncError{Frame: m.frame, Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+func (m *Movie) finish() {
+	m.finished = true
+	fmt.Printf("Movie %s finished after %d frames\n", m.filename, m.frame)
+}
+
+func (m *Movie) close() error {
+	defer m.file.Close()
+
+	if !m.recording {
+		return nil
+	}
+
+	if err := m.w.Flush(); err != nil {
+		return fmt.Errorf("could not save movie %s: %w", m.filename, err)
+	}
+
+	fmt.Printf("Saved %d frames to %s\n", m.frame, m.filename)
+	return nil
+}
+
+// stateHash hashes the CPU registers and everything visible on the bus, ignoring the PPU access locks
+func (gb *Gameboy) stateHash() uint64 {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, gb.cpu.reg)
+	binary.Write(&buf, binary.LittleEndian, []bool{gb.cpu.IME, gb.cpu.IMEDelay, gb.cpu.halted, gb.cpu.haltBug, gb.cpu.stopped, gb.cpu.locked})
+	binary.Write(&buf, binary.LittleEndian, int64(gb.cpu.ticks))
+
+	// state that never shows up on the bus, so a desync in the timer, PPU or DMA is caught straight away
+	binary.Write(&buf, binary.LittleEndian, []uint16{gb.timer.sysCounter, uint16(gb.timer.reloadState), gb.dmac.currByte})
+	binary.Write(&buf, binary.LittleEndian, []int64{int64(gb.ppu.ticks), int64(gb.ppu.mode3Ticks), int64(gb.dmac.pendingDelay), int64(gb.switchTicks)})
+	binary.Write(&buf, binary.LittleEndian, []uint8{uint8(gb.ppu.currState), gb.ppu.ly, gb.ppu.lx, gb.ppu.wly, gb.dmac.src, gb.dmac.pendingSrc})
+	binary.Write(&buf, binary.LittleEndian, []bool{gb.timer.lastSignal, gb.ppu.statLine, gb.ppu.inWindow, gb.ppu.lyWrapped, gb.ppu.disabled, gb.dmac.active, gb.dmac.pending})
+
+	for addr := 0; addr <= 0xFFFF; addr++ {
+		if p := gb.ppu.memPtr(uint16(addr)); p != nil {
+			buf.WriteByte(*p)
+		} else {
+			buf.WriteByte(gb.mmu.read(uint16(addr)))
+		}
+	}
+
+	h := fnv.New64a()
+	h.Write(buf.Bytes())
+	h.Write(gb.cart.ram)
+
+	return h.Sum64()
+}
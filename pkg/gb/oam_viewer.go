@@ -0,0 +1,186 @@
+package gb
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// OAMViewer lists all 40 OAM entries and shows which ones the OAM scan picks up on a given scanline
+type OAMViewer struct {
+	ppu    *PPU
+	buffer *ebiten.Image
+	tiles  *ebiten.Image
+
+	line    uint8
+	hovered bool
+	hits    [NUM_OAM_ENTRIES]OAMHit
+}
+
+type OAMHit uint8
+
+const (
+	OAM_VIEWER_COLS        = 4
+	OAM_VIEWER_ROWS        = NUM_OAM_ENTRIES / OAM_VIEWER_COLS
+	OAM_VIEWER_CELL_WIDTH  = 104
+	OAM_VIEWER_CELL_HEIGHT = 2 * DBG_CHAR_HEIGHT
+	OAM_VIEWER_TILE_SCALE  = 2
+
+	OAM_VIEWER_SCREEN_WIDTH  = OAM_VIEWER_COLS * OAM_VIEWER_CELL_WIDTH
+	OAM_VIEWER_SCREEN_HEIGHT = DBG_CHAR_HEIGHT + OAM_VIEWER_ROWS*OAM_VIEWER_CELL_HEIGHT
+
+	OAM_NOT_ON_LINE OAMHit = 0
+	OAM_SELECTED    OAMHit = 1
+	OAM_DROPPED     OAMHit = 2 // on the line, but past the 10 sprites per scanline limit
+)
+
+var (
+	dbgSelectedColor    = hexToRGBA(0x206020)
+	dbgTransparentColor = hexToRGBA(0x303030)
+)
+
+func newOAMViewer(ppu *PPU) *OAMViewer {
+	return &OAMViewer{
+		ppu:    ppu,
+		buffer: ebiten.NewImage(OAM_VIEWER_SCREEN_WIDTH, OAM_VIEWER_SCREEN_HEIGHT),
+		tiles:  ebiten.NewImage(NUM_OAM_ENTRIES*TILE_WIDTH, 2*TILE_WIDTH),
+	}
+}
+
+func (ov *OAMViewer) handleInput() {
+	// hovering over the game screen inspects that scanline, otherwise follow LY
+	_, cy, inside := cursorIn(0, GB_SCREEN_DBG_Y, GB_SCREEN_WIDTH, GB_SCREEN_HEIGHT)
+	ov.hovered = inside
+
+	if inside {
+		ov.line = uint8(cy)
+	} else {
+		ov.line = ov.ppu.ly
+	}
+}
+
+// scanLine mirrors the selection rules of PPU.scanOAM for the viewed scanline
+func (ov *OAMViewer) scanLine() int {
+	found := 0
+
+	for i := 0; i < NUM_OAM_ENTRIES; i++ {
+		ov.hits[i] = OAM_NOT_ON_LINE
+
+		if ov.line >= GB_SCREEN_HEIGHT || !ov.ppu.spriteOnLine(ov.ppu.oamEntry(i), ov.line) {
+			continue
+		}
+
+		if found < SPRITES_PER_SCANLINE {
+			ov.hits[i] = OAM_SELECTED
+		} else {
+			ov.hits[i] = OAM_DROPPED
+		}
+		found++
+	}
+
+	return found
+}
+
+func (ov *OAMViewer) writeSpriteTile(idx int, sprite Sprite) {
+	height := int(ov.ppu.getSpriteHeight())
+	tileId := uint16(sprite.tileId)
+	if height == 16 {
+		tileId &= 0xFE
+	}
+
+	pal := ov.ppu.spPalettes[0]
+	if sprite.getPalette() == OBP1 {
+		pal = ov.ppu.spPalettes[1]
+	}
+
+	for row := 0; row < height; row++ {
+		for col := 0; col < TILE_WIDTH; col++ {
+			x := idx*TILE_WIDTH + col
+
+			tileRow := row
+			if sprite.flippedY() {
+				tileRow = height - 1 - row
+			}
+
+			bit := 7 - col
+			if sprite.flippedX() {
+				bit = col
+			}
+
+			addr := tileId*TILE_SIZE + uint16(tileRow*2)
+			color := getColor(ov.ppu.vram[addr], ov.ppu.vram[addr+1], uint8(bit))
+
+			if color == 0 {
+				ov.tiles.Set(x, row, dbgTransparentColor)
+			} else {
+				ov.tiles.Set(x, row, getLCDColor(pal, color))
+			}
+		}
+	}
+}
+
+func (ov *OAMViewer) draw(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	ov.buffer.Fill(dbgBackground)
+
+	found := ov.scanLine()
+	source := "LY"
+	if ov.hovered {
+		source = "hover"
+	}
+
+	header := fmt.Sprintf("OAM  line %3d (%s): %d on line", ov.line, source, found)
+	if found > SPRITES_PER_SCANLINE {
+		header += fmt.Sprintf(", %d dropped", found-SPRITES_PER_SCANLINE)
+	}
+	dbgPrint(ov.buffer, header, 0, 0)
+
+	for i := 0; i < NUM_OAM_ENTRIES; i++ {
+		sprite := ov.ppu.oamEntry(i)
+		ov.writeSpriteTile(i, sprite)
+
+		cellX := (i % OAM_VIEWER_COLS) * OAM_VIEWER_CELL_WIDTH
+		cellY := DBG_CHAR_HEIGHT + (i/OAM_VIEWER_COLS)*OAM_VIEWER_CELL_HEIGHT
+
+		switch ov.hits[i] {
+		case OAM_SELECTED:
+			vector.DrawFilledRect(ov.buffer, float32(cellX), float32(cellY), OAM_VIEWER_CELL_WIDTH-2, OAM_VIEWER_CELL_HEIGHT-1, dbgSelectedColor, false)
+		case OAM_DROPPED:
+			vector.DrawFilledRect(ov.buffer, float32(cellX), float32(cellY), OAM_VIEWER_CELL_WIDTH-2, OAM_VIEWER_CELL_HEIGHT-1, dbgHighlight, false)
+		}
+
+		height := int(ov.ppu.getSpriteHeight())
+		tile := ov.tiles.SubImage(image.Rect(i*TILE_WIDTH, 0, (i+1)*TILE_WIDTH, height)).(*ebiten.Image)
+
+		tileOpt := ebiten.DrawImageOptions{}
+		tileOpt.GeoM.Scale(OAM_VIEWER_TILE_SCALE, OAM_VIEWER_TILE_SCALE)
+		tileOpt.GeoM.Translate(float64(cellX), float64(cellY))
+		ov.buffer.DrawImage(tile, &tileOpt)
+
+		flips := []byte("--")
+		if sprite.flippedX() {
+			flips[0] = 'X'
+		}
+		if sprite.flippedY() {
+			flips[1] = 'Y'
+		}
+
+		priority := "  "
+		if sprite.getBGPriority() {
+			priority = "BG"
+		}
+
+		pal := 0
+		if sprite.getPalette() == OBP1 {
+			pal = 1
+		}
+
+		textX := cellX + OAM_VIEWER_TILE_SCALE*TILE_WIDTH + 4
+		ebitenutil.DebugPrintAt(ov.buffer, fmt.Sprintf("%02d X%02X Y%02X", i, sprite.x, sprite.y), textX, cellY)
+		ebitenutil.DebugPrintAt(ov.buffer, fmt.Sprintf("T%02X O%d %s %s", sprite.tileId, pal, flips, priority), textX, cellY+DBG_CHAR_HEIGHT)
+	}
+
+	screen.DrawImage(ov.buffer, opt)
+}
@@ -2,23 +2,32 @@ package gb
 
 import (
 	"cmp"
+	"fmt"
+	"image"
 	"image/color"
-	"log"
 	"slices"
 
 	"github.com/BeralaWoolies/GameboyGo/pkg/bits"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 type PPU struct {
-	mmu               *MMU
-	dmac              *DMAController
-	ic                *IntruptController
-	pxF               *PixelFIFO
-	frameBuffer       []byte
-	screen            *ebiten.Image
-	dbgTileDataBuffer *ebiten.Image
-	dbgTileMapBuffer  *ebiten.Image
+	mmu              *MMU
+	dmac             *DMAController
+	ic               *IntruptController
+	pxF              *PixelFIFO
+	frameBuffer      []byte
+	screen           *ebiten.Image
+	dbgTileMapBuffer *ebiten.Image
+	rasterLog        *RasterLog // only set in debug mode
+	skipRender       bool       // leave the screen alone at VBlank, for frames that are never shown
+	onVBlank         func()
+
+	// VRAM/OAM are inaccessible to the CPU in some modes, onBlockedAccess lets violations be reported
+	accessLocks     bool
+	onBlockedAccess func(addr uint16, write bool)
 
 	vram          [VRAM_SIZE]uint8
 	oam           [OAM_SIZE]uint8
@@ -44,6 +53,10 @@ type PPU struct {
 	lx        uint8
 	inWindow  bool
 	disabled  bool
+
+	statLine   bool // all STAT interrupt sources are OR'd onto this line, interrupts fire on its rising edge
+	mode3Ticks int  // how long pixel transfer takes on hardware for the current scanline
+	lyWrapped  bool // LY already reads 0 for most of line 153
 }
 
 type PPUState uint8
@@ -103,6 +116,8 @@ const (
 	LCDC_LCD_ENABLE     = 7
 
 	SPRITES_PER_SCANLINE = 10
+	OAM_ENTRY_SIZE       = 4
+	NUM_OAM_ENTRIES      = OAM_SIZE / OAM_ENTRY_SIZE
 
 	OAM_SCAN       PPUState = 2
 	PIXEL_TRANSFER PPUState = 3
@@ -110,6 +125,21 @@ const (
 	VBLANK         PPUState = 1
 
 	OAM_SCAN_TICKS = 80
+
+	// the LYC comparison only sees a new LY value a few dots into the line
+	LYC_COMPARE_TICKS  = 4
+	LY_153_RESET_TICKS = 8
+
+	MODE3_BASE_TICKS      = 172
+	MODE3_WINDOW_PENALTY  = 6
+	MODE3_SPRITE_PENALTY  = 6
+	MODE3_SPRITE_X0_TICKS = 11
+	MODE3_MAX_FETCH_STALL = 5
+	OFFSCREEN_SPRITE_X    = GB_SCREEN_WIDTH + 8
+
+	// the window starts at screen x WX-7, so a WX past the right edge of the screen never shows it
+	WINDOW_X_OFFSET = 7
+	MAX_WINDOW_WX   = GB_SCREEN_WIDTH + WINDOW_X_OFFSET - 1
 )
 
 var pallete = [4]color.RGBA{
@@ -136,12 +166,12 @@ func (ppu *PPU) init(mmu *MMU, dmac *DMAController, ic *IntruptController) {
 	ppu.pxF.init(ppu)
 	ppu.frameBuffer = make([]byte, 4*GB_SCREEN_WIDTH*GB_SCREEN_HEIGHT)
 	ppu.screen = ebiten.NewImage(GB_SCREEN_WIDTH, GB_SCREEN_HEIGHT)
-	ppu.dbgTileDataBuffer = ebiten.NewImage(TILE_DATA_SCREEN_WIDTH, TILE_DATA_SCREEN_HEIGHT)
 	ppu.dbgTileMapBuffer = ebiten.NewImage(2*TILE_MAP_SCREEN_WIDTH, TILE_MAP_SCREEN_HEIGHT)
 
 	ppu.vram = [VRAM_SIZE]uint8{}
 	ppu.oam = [OAM_SIZE]uint8{}
 	ppu.spriteBuffer = make([]Sprite, 0, SPRITES_PER_SCANLINE)
+	ppu.accessLocks = true
 	ppu.setState(OAM_SCAN)
 }
 
@@ -158,6 +188,10 @@ func (ppu *PPU) tick() {
 
 	ppu.ticks++
 
+	if ppu.ticks == LYC_COMPARE_TICKS {
+		ppu.compareLYC()
+	}
+
 	switch ppu.currState {
 	case OAM_SCAN:
 		ppu.scanOAM()
@@ -170,44 +204,16 @@ func (ppu *PPU) tick() {
 			ppu.setState(PIXEL_TRANSFER)
 		}
 	case PIXEL_TRANSFER:
-		if ppu.winEnabled() && ppu.winEncountered() {
-			if !ppu.pxF.windowFetch {
-				ppu.pxF.startWinFetch()
-			}
-		} else {
-			if ppu.pxF.windowFetch {
-				ppu.pxF.startBGFetch()
-			}
-		}
-
-		for ppu.spritesEnabled() && ppu.spriteEncountered() {
-			ppu.pxF.spriteFetchFIFO.Add(ppu.spriteBuffer[0])
-			ppu.spriteBuffer = ppu.spriteBuffer[1:]
-		}
-
-		ppu.pxF.tick()
-
-		if pxFItem, err := ppu.pxF.pop(); err == nil {
-			var color color.RGBA
-
-			switch pxFItem.palette {
-			case BGP:
-				color = getLCDColor(ppu.bgPalette, pxFItem.color)
-			case OBP0:
-				color = getLCDColor(ppu.spPalettes[0], pxFItem.color)
-			case OBP1:
-				color = getLCDColor(ppu.spPalettes[1], pxFItem.color)
+		ppu.fetchDot()
+
+		if ppu.ticks >= OAM_SCAN_TICKS+ppu.mode3Ticks {
+			// the FIFO is not dot accurate, so the length of mode 3 comes from calcMode3Ticks instead.
+			// If the FIFO is still behind at that point, finish the line off straight away, still
+			// starting the window and fetching sprites as they are reached
+			for i := 0; ppu.lx < GB_SCREEN_WIDTH && i < TICKS_PER_SCANLINE; i++ {
+				ppu.fetchDot()
 			}
 
-			offset := 4 * ((int(ppu.ly) * GB_SCREEN_WIDTH) + int(ppu.lx))
-			ppu.frameBuffer[offset] = color.R
-			ppu.frameBuffer[offset+1] = color.G
-			ppu.frameBuffer[offset+2] = color.B
-			ppu.frameBuffer[offset+3] = color.A
-			ppu.lx++
-		}
-
-		if ppu.lx >= GB_SCREEN_WIDTH {
 			// end of pixel transfer, move to HBLANK
 			ppu.setState(HBLANK)
 
@@ -231,19 +237,126 @@ func (ppu *PPU) tick() {
 			}
 		}
 	case VBLANK:
+		if ppu.ly == SCANLINES_PER_FRAME-1 && ppu.ticks == LY_153_RESET_TICKS {
+			// LY only reads 153 for the start of the last line, so LYC=0 matches on line 153
+			ppu.lyWrapped = true
+			ppu.setLY(0)
+		} else if ppu.lyWrapped && ppu.ticks == LY_153_RESET_TICKS+LYC_COMPARE_TICKS {
+			ppu.compareLYC()
+		}
+
 		if ppu.ticks >= TICKS_PER_SCANLINE {
 			ppu.resetTicks()
-			ppu.incLY()
 
-			if ppu.ly >= SCANLINES_PER_FRAME {
-				ppu.resetLY()
+			if ppu.lyWrapped {
+				if ppu.rasterLog != nil {
+					ppu.rasterLog.endFrame(ppu)
+				}
+
+				ppu.lyWrapped = false
 				ppu.latchWindow()
 				ppu.setState(OAM_SCAN)
+			} else {
+				ppu.incLY()
 			}
 		}
 	default:
-		log.Fatalf("PPU is in an unimplemented state")
+		raiseFault(fmt.Errorf("PPU is in an unimplemented state: %d", ppu.currState))
+	}
+}
+
+// fetchDot runs the pixel FIFO for a dot, switching between the background and window and queueing up
+// sprite fetches as the current x position reaches them
+func (ppu *PPU) fetchDot() {
+	if ppu.winEnabled() && ppu.winEncountered() {
+		if !ppu.pxF.windowFetch {
+			ppu.pxF.startWinFetch()
+		}
+	} else {
+		if ppu.pxF.windowFetch {
+			ppu.pxF.startBGFetch()
+		}
+	}
+
+	for ppu.spritesEnabled() && ppu.spriteEncountered() {
+		ppu.pxF.spriteFetchFIFO.Add(ppu.spriteBuffer[0])
+		ppu.spriteBuffer = ppu.spriteBuffer[1:]
+	}
+
+	if ppu.lx < GB_SCREEN_WIDTH {
+		ppu.transferPixel()
+	}
+}
+
+func (ppu *PPU) transferPixel() {
+	ppu.pxF.tick()
+
+	if pxFItem, err := ppu.pxF.pop(); err == nil {
+		var color color.RGBA
+
+		switch pxFItem.palette {
+		case BGP:
+			color = getLCDColor(ppu.bgPalette, pxFItem.color)
+		case OBP0:
+			color = getLCDColor(ppu.spPalettes[0], pxFItem.color)
+		case OBP1:
+			color = getLCDColor(ppu.spPalettes[1], pxFItem.color)
+		}
+
+		offset := 4 * ((int(ppu.ly) * GB_SCREEN_WIDTH) + int(ppu.lx))
+		ppu.frameBuffer[offset] = color.R
+		ppu.frameBuffer[offset+1] = color.G
+		ppu.frameBuffer[offset+2] = color.B
+		ppu.frameBuffer[offset+3] = color.A
+		ppu.lx++
+	}
+}
+
+// calcMode3Ticks works out the length of pixel transfer for the current scanline: the SCX fine scroll
+// is discarded a dot at a time, starting the window restarts the fetcher, and every sprite fetch
+// stalls the FIFO while also waiting on the background fetch of the tile it lands on
+func (ppu *PPU) calcMode3Ticks() int {
+	ticks := MODE3_BASE_TICKS + int(ppu.scx%8)
+
+	winActive := ppu.winEnabled() && ppu.inWindow && int(ppu.wx) <= MAX_WINDOW_WX
+	if winActive {
+		ticks += MODE3_WINDOW_PENALTY
+	}
+
+	if !ppu.spritesEnabled() {
+		return ticks
+	}
+
+	// bit n is set once a sprite has waited on the fetch of background or window tile n
+	var bgFetched, winFetched uint64
+	for _, sprite := range ppu.spriteBuffer {
+		if sprite.x >= OFFSCREEN_SPRITE_X {
+			continue
+		}
+
+		if sprite.x == 0 {
+			ticks += MODE3_SPRITE_X0_TICKS
+			continue
+		}
+
+		// which background/window tile the sprite's leftmost pixel lands on, and how far into it
+		tile := (int(sprite.x) + int(ppu.scx)) / TILE_WIDTH
+		offset := (int(sprite.x) + int(ppu.scx)) % TILE_WIDTH
+		fetched := &bgFetched
+		if winActive && int(sprite.x) > int(ppu.wx) {
+			tile = (int(sprite.x) - int(ppu.wx) - 1) / TILE_WIDTH
+			offset = (int(sprite.x) - int(ppu.wx) - 1) % TILE_WIDTH
+			fetched = &winFetched
+		}
+
+		ticks += MODE3_SPRITE_PENALTY
+		if *fetched&(1<<tile) == 0 {
+			*fetched |= 1 << tile
+			ticks += max(0, MODE3_MAX_FETCH_STALL-offset)
+		}
 	}
+
+	return ticks
 }
 
 func (ppu *PPU) latchWindow() {
@@ -265,22 +378,29 @@ func (ppu *PPU) scanOAM() {
 		return
 	}
 
-	spriteY := ppu.mmu.read(ppu.oamScan)
-	spriteX := ppu.mmu.read(ppu.oamScan + 1)
-	spriteTileId := ppu.mmu.read(ppu.oamScan + 2)
-	spriteFlags := ppu.mmu.read(ppu.oamScan + 3)
+	sprite := ppu.oamEntry(int(ppu.oamScan-OAM_BASE) / OAM_ENTRY_SIZE)
 
-	if ppu.ly+16 >= spriteY && ppu.ly+16 < spriteY+ppu.getSpriteHeight() {
-		ppu.spriteBuffer = append(ppu.spriteBuffer, Sprite{
-			x:      spriteX,
-			y:      spriteY,
-			tileId: spriteTileId,
-			flags:  spriteFlags,
-		})
+	if ppu.spriteOnLine(sprite, ppu.ly) {
+		ppu.spriteBuffer = append(ppu.spriteBuffer, sprite)
 		ppu.spritesOnLine++
 	}
 
-	ppu.oamScan += 4
+	ppu.oamScan += OAM_ENTRY_SIZE
+}
+
+func (ppu *PPU) oamEntry(idx int) Sprite {
+	offset := idx * OAM_ENTRY_SIZE
+
+	return Sprite{
+		y:      ppu.oam[offset],
+		x:      ppu.oam[offset+1],
+		tileId: ppu.oam[offset+2],
+		flags:  ppu.oam[offset+3],
+	}
+}
+
+func (ppu *PPU) spriteOnLine(sprite Sprite, ly uint8) bool {
+	return ly+16 >= sprite.y && ly+16 < sprite.y+ppu.getSpriteHeight()
 }
 
 func (ppu *PPU) getSpriteHeight() uint8 {
@@ -352,25 +472,49 @@ func (ppu *PPU) updateStat(state PPUState) {
 		ppu.oamScan = OAM_BASE
 		ppu.spritesOnLine = 0
 		ppu.spriteBuffer = ppu.spriteBuffer[:0]
-
-		if bits.IsSet(ppu.stat, STAT_SELECT_OAM) {
-			ppu.ic.requestIntrupt(LCD_INTRUPT_BIT)
-		}
 	case PIXEL_TRANSFER:
 		ppu.lx = 0
 		ppu.pxF.start()
-	case HBLANK:
-		if bits.IsSet(ppu.stat, STAT_SELECT_HBLANK) {
-			ppu.ic.requestIntrupt(LCD_INTRUPT_BIT)
-		}
+		ppu.mode3Ticks = ppu.calcMode3Ticks()
 	case VBLANK:
-		ppu.screen.WritePixels(ppu.frameBuffer)
+		if !ppu.skipRender {
+			ppu.screen.WritePixels(ppu.frameBuffer)
+		}
 		ppu.ic.requestIntrupt(VBLANK_INTRUPT_BIT)
 
-		if bits.IsSet(ppu.stat, STAT_SELECT_VBLANK) {
-			ppu.ic.requestIntrupt(LCD_INTRUPT_BIT)
+		if ppu.onVBlank != nil {
+			ppu.onVBlank()
+		}
+	}
+
+	ppu.updateStatLine()
+}
+
+// updateStatLine ORs together every enabled STAT source. An interrupt is only requested on a rising edge,
+// so a source going high while another one already holds the line high is blocked
+func (ppu *PPU) updateStatLine() {
+	line := false
+
+	if !ppu.disabled {
+		switch ppu.currState {
+		case HBLANK:
+			line = bits.IsSet(ppu.stat, STAT_SELECT_HBLANK)
+		case VBLANK:
+			// the OAM source also fires as line 144 starts on DMG
+			line = bits.IsSet(ppu.stat, STAT_SELECT_VBLANK) ||
+				(ppu.ly == GB_SCREEN_HEIGHT && ppu.ticks == 0 && bits.IsSet(ppu.stat, STAT_SELECT_OAM))
+		case OAM_SCAN:
+			line = bits.IsSet(ppu.stat, STAT_SELECT_OAM)
 		}
+
+		line = line || (bits.IsSet(ppu.stat, STAT_LYC) && bits.IsSet(ppu.stat, STAT_SELECT_LYC))
+	}
+
+	if line && !ppu.statLine {
+		ppu.ic.requestIntrupt(LCD_INTRUPT_BIT)
 	}
+
+	ppu.statLine = line
 }
 
 func (ppu *PPU) getBGTileMap() uint16 {
@@ -406,17 +550,24 @@ func (ppu *PPU) resetLY() {
 }
 
 func (ppu *PPU) incLY() {
-	ppu.ly++
+	ppu.setLY(ppu.ly + 1)
+}
 
+// setLY changes LY, the LYC flag reads clear until compareLYC catches up with the new value
+func (ppu *PPU) setLY(ly uint8) {
+	ppu.ly = ly
+	ppu.stat = bits.Reset(ppu.stat, STAT_LYC)
+	ppu.updateStatLine()
+}
+
+func (ppu *PPU) compareLYC() {
 	if ppu.ly == ppu.lyc {
 		ppu.stat = bits.Set(ppu.stat, STAT_LYC)
-
-		if bits.IsSet(ppu.stat, STAT_SELECT_LYC) {
-			ppu.ic.requestIntrupt(LCD_INTRUPT_BIT)
-		}
 	} else {
 		ppu.stat = bits.Reset(ppu.stat, STAT_LYC)
 	}
+
+	ppu.updateStatLine()
 }
 
 func (ppu *PPU) setStatDirect(val uint8) {
@@ -427,6 +578,51 @@ func (ppu *PPU) setDMADirect(val uint8) {
 	ppu.dma = val
 }
 
+// vramLocked reports whether the CPU is locked out of VRAM, which is while pixels are being transferred
+func (ppu *PPU) vramLocked() bool {
+	return ppu.accessLocks && !ppu.disabled && ppu.currState == PIXEL_TRANSFER
+}
+
+// oamLocked reports whether the CPU is locked out of OAM, which is during OAM scan, pixel transfer and OAM DMA
+func (ppu *PPU) oamLocked() bool {
+	if !ppu.accessLocks {
+		return false
+	}
+
+	return ppu.dmac.active || (!ppu.disabled && (ppu.currState == OAM_SCAN || ppu.currState == PIXEL_TRANSFER))
+}
+
+func (ppu *PPU) accessBlocked(addr uint16, write bool) bool {
+	blocked := (inRange(addr, VRAM_BASE, VRAM_TOP) && ppu.vramLocked()) ||
+		(inRange(addr, OAM_BASE, OAM_TOP) && ppu.oamLocked())
+
+	if blocked && ppu.onBlockedAccess != nil {
+		ppu.onBlockedAccess(addr, write)
+	}
+
+	return blocked
+}
+
+// scanline is the line being drawn, which differs from LY for most of line 153
+func (ppu *PPU) scanline() uint8 {
+	if ppu.lyWrapped {
+		return SCANLINES_PER_FRAME - 1
+	}
+
+	return ppu.ly
+}
+
+// memPtr returns the byte backing a VRAM or OAM address regardless of the access locks, or nil
+func (ppu *PPU) memPtr(addr uint16) *uint8 {
+	if inRange(addr, VRAM_BASE, VRAM_TOP) {
+		return &ppu.vram[addr-VRAM_BASE]
+	} else if inRange(addr, OAM_BASE, OAM_TOP) {
+		return &ppu.oam[addr-OAM_BASE]
+	}
+
+	return nil
+}
+
 func (ppu *PPU) contains(addr uint16) bool {
 	return (inRange(addr, VRAM_BASE, VRAM_TOP) ||
 		inRange(addr, OAM_BASE, OAM_TOP) ||
@@ -434,6 +630,10 @@ func (ppu *PPU) contains(addr uint16) bool {
 }
 
 func (ppu *PPU) write(addr uint16, data uint8) {
+	if ppu.accessBlocked(addr, true) {
+		return
+	}
+
 	if inRange(addr, VRAM_BASE, VRAM_TOP) {
 		ppu.vram[addr-VRAM_BASE] = data
 		return
@@ -442,12 +642,17 @@ func (ppu *PPU) write(addr uint16, data uint8) {
 		return
 	}
 
+	if ppu.rasterLog != nil && addr != LY_ADDR {
+		ppu.rasterLog.record(addr, data, ppu.scanline(), ppu.ticks)
+	}
+
 	switch addr {
 	case LCDC_ADDR:
 		ppu.lcdc = data
 
 		if !ppu.LCDEnabled() {
 			ppu.disabled = true
+			ppu.lyWrapped = false
 			ppu.resetLY()
 			ppu.resetTicks()
 			ppu.setState(HBLANK)
@@ -456,7 +661,9 @@ func (ppu *PPU) write(addr uint16, data uint8) {
 			ppu.setState(OAM_SCAN)
 		}
 	case STAT_ADDR:
-		ppu.stat = (data & STAT_RW_MSK) | 0x80
+		// the mode and LYC flag bits are read only
+		ppu.stat = (data & STAT_RW_MSK) | (ppu.stat &^ STAT_RW_MSK) | 0x80
+		ppu.updateStatLine()
 	case SCY_ADDR:
 		ppu.scy = data
 	case SCX_ADDR:
@@ -466,6 +673,9 @@ func (ppu *PPU) write(addr uint16, data uint8) {
 		return
 	case LYC_ADDR:
 		ppu.lyc = data
+		if !ppu.disabled && ppu.ticks >= LYC_COMPARE_TICKS {
+			ppu.compareLYC()
+		}
 	case OAM_DMA_TRANSFER_ADDR:
 		ppu.dma = data
 		ppu.dmac.initOAMTransfer(data)
@@ -480,11 +690,15 @@ func (ppu *PPU) write(addr uint16, data uint8) {
 	case WX_ADDR:
 		ppu.wx = data
 	default:
-		log.Fatalf("MMU mapped an illegal write address: 0x%02x to PPU", addr)
+		busFault("PPU", addr, true)
 	}
 }
 
 func (ppu *PPU) read(addr uint16) uint8 {
+	if ppu.accessBlocked(addr, false) {
+		return 0xFF
+	}
+
 	if inRange(addr, VRAM_BASE, VRAM_TOP) {
 		return ppu.vram[addr-VRAM_BASE]
 	} else if inRange(addr, OAM_BASE, OAM_TOP) {
@@ -517,7 +731,7 @@ func (ppu *PPU) read(addr uint16) uint8 {
 	case WX_ADDR:
 		return ppu.wx
 	default:
-		log.Fatalf("MMU mapped an illegal read address: 0x%02x to PPU", addr)
+		busFault("PPU", addr, false)
 		return 0xFF
 	}
 }
@@ -527,22 +741,30 @@ func (ppu *PPU) updateGBScreen(screen *ebiten.Image, opt *ebiten.DrawImageOption
 }
 
 func (ppu *PPU) writeTile(buffer *ebiten.Image, tileId uint16, x int, y int) {
+	colors := paletteColors(ppu.bgPalette)
+	writeTileData(buffer, &ppu.vram, ppu.tileDataAddr(uint8(tileId)), &colors, x, y)
+}
+
+// tileDataAddr returns where the tile id points to, using the tile data area LCDC currently selects
+func (ppu *PPU) tileDataAddr(tileId uint8) uint16 {
 	addr, unsig := ppu.getTileDataArea()
-	addr -= VRAM_BASE
 
 	if unsig {
-		addr += (uint16(tileId) * TILE_SIZE)
-	} else {
-		addr += (uint16(int(int8(tileId)) * TILE_SIZE))
+		return addr + (uint16(tileId) * TILE_SIZE)
 	}
 
+	return addr + (uint16(int(int8(tileId)) * TILE_SIZE))
+}
+
+func writeTileData(buffer *ebiten.Image, vram *[VRAM_SIZE]uint8, addr uint16, colors *[4]color.RGBA, x int, y int) {
+	addr -= VRAM_BASE
+
 	for tileRow := 0; tileRow < 16; tileRow += 2 {
-		loByte := ppu.vram[addr+uint16(tileRow)]
-		hiByte := ppu.vram[addr+uint16(tileRow)+1]
+		loByte := vram[addr+uint16(tileRow)]
+		hiByte := vram[addr+uint16(tileRow)+1]
 
 		for bit := 7; bit >= 0; bit-- {
-			color := getLCDColor(ppu.bgPalette, getColor(loByte, hiByte, uint8(bit)))
-			buffer.Set(x+(7-bit), y+(tileRow/2), color)
+			buffer.Set(x+(7-bit), y+(tileRow/2), colors[getColor(loByte, hiByte, uint8(bit))])
 		}
 	}
 }
@@ -558,20 +780,11 @@ func getLCDColor(pal uint8, color uint8) color.RGBA {
 	return pallete[(pal>>(2*color))&3]
 }
 
-// ============================= Debug Functions ===============================
-func (ppu *PPU) updateTileDataScreen(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
-	var tileId uint16 = 0
-
-	for y := 0; y < TILE_DATA_SCREEN_HEIGHT/TILE_WIDTH; y++ {
-		for x := 0; x < TILE_DATA_SCREEN_WIDTH/TILE_WIDTH; x++ {
-			ppu.writeTile(ppu.dbgTileDataBuffer, tileId, (x * TILE_WIDTH), (y * TILE_WIDTH))
-			tileId++
-		}
-	}
-
-	screen.DrawImage(ppu.dbgTileDataBuffer, opt)
+func paletteColors(pal uint8) [4]color.RGBA {
+	return [4]color.RGBA{getLCDColor(pal, 0), getLCDColor(pal, 1), getLCDColor(pal, 2), getLCDColor(pal, 3)}
 }
 
+// ============================= Debug Functions ===============================
 func (ppu *PPU) updateTileMaps(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
 	var tileMap1 uint16 = 0x9800
 	var tileMap2 uint16 = 0x9C00
@@ -586,5 +799,89 @@ func (ppu *PPU) updateTileMaps(screen *ebiten.Image, opt *ebiten.DrawImageOption
 		}
 	}
 
+	ppu.drawViewportOverlay()
+
+	if tileMap, tileX, tileY, ok := ppu.hoveredMapTile(); ok {
+		x := tileMapOffsetX(tileMap) + tileX*TILE_WIDTH
+		vector.StrokeRect(ppu.dbgTileMapBuffer, float32(x), float32(tileY*TILE_WIDTH), TILE_WIDTH, TILE_WIDTH, 1, dbgHoverColor, false)
+	}
+
 	screen.DrawImage(ppu.dbgTileMapBuffer, opt)
 }
+
+// drawViewportOverlay outlines the visible BG region (SCX/SCY) and window region (WX/WY) on the tile maps they are
+// fetched from
+func (ppu *PPU) drawViewportOverlay() {
+	ppu.strokeWrappedRect(ppu.getBGTileMap(), int(ppu.scx), int(ppu.scy), GB_SCREEN_WIDTH, GB_SCREEN_HEIGHT, dbgViewportColor)
+
+	if !ppu.winEnabled() || ppu.wx > MAX_WINDOW_WX || ppu.wy >= GB_SCREEN_HEIGHT {
+		return
+	}
+
+	// the window is drawn from the top left of its tile map, except that a WX below 7 pushes its
+	// first columns off the left edge of the screen
+	winX := max(WINDOW_X_OFFSET-int(ppu.wx), 0)
+	winWidth := GB_SCREEN_WIDTH - max(int(ppu.wx)-WINDOW_X_OFFSET, 0)
+	winHeight := GB_SCREEN_HEIGHT - int(ppu.wy)
+	ppu.strokeWrappedRect(ppu.getWinTileMap(), winX, 0, winWidth, winHeight, dbgWindowColor)
+}
+
+func (ppu *PPU) strokeWrappedRect(tileMap uint16, x int, y int, width int, height int, clr color.Color) {
+	mapX := tileMapOffsetX(tileMap)
+	mapImg := ppu.dbgTileMapBuffer.SubImage(image.Rect(mapX, 0, mapX+TILE_MAP_SCREEN_WIDTH, TILE_MAP_SCREEN_HEIGHT)).(*ebiten.Image)
+
+	// draw the rect at each wrapped position, clipping to the tile map keeps only the visible parts
+	for _, dx := range []int{0, -TILE_MAP_SCREEN_WIDTH} {
+		for _, dy := range []int{0, -TILE_MAP_SCREEN_HEIGHT} {
+			vector.StrokeRect(mapImg, float32(mapX+x+dx)+0.5, float32(y+dy)+0.5, float32(width-1), float32(height-1), 1, clr, false)
+		}
+	}
+}
+
+func tileMapOffsetX(tileMap uint16) int {
+	if tileMap == 0x9C00 {
+		return TILE_MAP_SCREEN_WIDTH
+	}
+
+	return 0
+}
+
+func (ppu *PPU) hoveredMapTile() (tileMap uint16, tileX int, tileY int, ok bool) {
+	cx, cy, inside := cursorIn(TILE_MAPS_DBG_X, 0, 2*TILE_MAP_SCREEN_WIDTH, TILE_MAP_SCREEN_HEIGHT)
+	if !inside {
+		return 0, 0, 0, false
+	}
+
+	tileMap = 0x9800
+	if cx >= TILE_MAP_SCREEN_WIDTH {
+		tileMap = 0x9C00
+		cx -= TILE_MAP_SCREEN_WIDTH
+	}
+
+	return tileMap, cx / TILE_WIDTH, cy / TILE_WIDTH, true
+}
+
+// updateTileMapInfo shows the map address, tile id and tile data address of the hovered tile map entry
+func (ppu *PPU) updateTileMapInfo(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	tileMap, tileX, tileY, ok := ppu.hoveredMapTile()
+	if !ok {
+		return
+	}
+
+	mapAddr := tileMap + uint16(tileY*TILE_MAP_WIDTH+tileX)
+	tileId := ppu.vram[mapAddr-VRAM_BASE]
+
+	dataAddr := ppu.tileDataAddr(tileId)
+
+	layers := ""
+	if tileMap == ppu.getBGTileMap() {
+		layers += " BG"
+	}
+	if ppu.winEnabled() && tileMap == ppu.getWinTileMap() {
+		layers += " WIN"
+	}
+
+	x, y := opt.GeoM.Apply(0, 0)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("MAP %04X%s\nTILE (%02d,%02d) @ %04X\nID   %02X\nDATA %04X", tileMap, layers,
+		tileX, tileY, mapAddr, tileId, dataAddr), int(x), int(y))
+}
@@ -0,0 +1,99 @@
+package gb
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+// newTestPPU returns a PPU at the start of line 0 without a screen, using tile 1 (colour 3) for sprites,
+// tile 0 (colour 0) for the background and tile 2 (colour 1) for the window
+func newTestPPU() *PPU {
+	ppu := &PPU{ic: &IntruptController{}}
+	ppu.pxF = &PixelFIFO{}
+	ppu.pxF.init(ppu)
+	ppu.frameBuffer = make([]byte, 4*GB_SCREEN_WIDTH*GB_SCREEN_HEIGHT)
+	ppu.spriteBuffer = make([]Sprite, 0, SPRITES_PER_SCANLINE)
+
+	ppu.lcdc = 1<<LCDC_LCD_ENABLE | 1<<LCDC_TILE_DATA_AREA | 1<<LCDC_OBJ_ENABLE | 1<<LCDC_BGWIN_ENABLE | 1<<LCDC_WIN_ENABLE | 1<<LCDC_WIN_TILE_MAP
+	ppu.bgPalette = 0xE4
+	ppu.spPalettes = [NUM_SP_PALETTES]uint8{0xE4, 0xE4}
+
+	copy(ppu.vram[1*TILE_SIZE:], bytes.Repeat([]byte{0xFF}, TILE_SIZE))
+	copy(ppu.vram[2*TILE_SIZE:], bytes.Repeat([]byte{0xFF, 0x00}, TILE_SIZE/2))
+	copy(ppu.vram[0x9C00-VRAM_BASE:], bytes.Repeat([]byte{2}, TILE_MAP_WIDTH*TILE_MAP_WIDTH))
+
+	ppu.setState(OAM_SCAN)
+	return ppu
+}
+
+func (ppu *PPU) pixel(x int) color.RGBA {
+	offset := 4 * x
+	return color.RGBA{ppu.frameBuffer[offset], ppu.frameBuffer[offset+1], ppu.frameBuffer[offset+2], ppu.frameBuffer[offset+3]}
+}
+
+// TestPPUBusyLine stacks every sprite on one tile just left of the window, which calcMode3Ticks budgets less
+// for than the FIFO takes to fetch them, so the end of the line is drawn after mode 3 has run out
+func TestPPUBusyLine(t *testing.T) {
+	const spriteStart = 140
+	const winStart = 150
+
+	ppu := newTestPPU()
+	ppu.wx = winStart + WINDOW_X_OFFSET
+	ppu.inWindow = true
+	for i := 0; i < SPRITES_PER_SCANLINE; i++ {
+		// y 16 puts the top of the sprite on line 0, and x is offset by 8 the same way
+		copy(ppu.oam[i*OAM_ENTRY_SIZE:], []byte{16, spriteStart + 8, 1, 0})
+	}
+
+	for i := 0; i < TICKS_PER_SCANLINE; i++ {
+		ppu.tick()
+	}
+
+	if ppu.ly != 1 {
+		t.Fatalf("LY is %d after a line, want 1", ppu.ly)
+	}
+
+	for x := 0; x < GB_SCREEN_WIDTH; x++ {
+		want := pallete[0]
+		if x >= winStart {
+			want = pallete[1]
+		} else if x >= spriteStart && x < spriteStart+TILE_WIDTH {
+			want = pallete[3]
+		}
+
+		if got := ppu.pixel(x); got != want {
+			t.Errorf("pixel %d is %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestCalcMode3Ticks(t *testing.T) {
+	tests := []struct {
+		name    string
+		scx     uint8
+		sprites []uint8 // x positions
+		ticks   int
+	}{
+		{"no sprites", 0, nil, MODE3_BASE_TICKS},
+		{"fine scroll", 3, nil, MODE3_BASE_TICKS + 3},
+		{"sprite at x 0", 0, []uint8{0}, MODE3_BASE_TICKS + MODE3_SPRITE_X0_TICKS},
+		{"offscreen sprite", 0, []uint8{OFFSCREEN_SPRITE_X}, MODE3_BASE_TICKS},
+		{"aligned sprite", 0, []uint8{8}, MODE3_BASE_TICKS + MODE3_SPRITE_PENALTY + MODE3_MAX_FETCH_STALL},
+		{"two sprites on a tile", 0, []uint8{8, 10}, MODE3_BASE_TICKS + 2*MODE3_SPRITE_PENALTY + MODE3_MAX_FETCH_STALL},
+		{"sprites on two tiles", 0, []uint8{8, 18}, MODE3_BASE_TICKS + 2*MODE3_SPRITE_PENALTY + MODE3_MAX_FETCH_STALL + 3},
+		{"sprite late in a tile", 0, []uint8{14}, MODE3_BASE_TICKS + MODE3_SPRITE_PENALTY},
+	}
+
+	for _, tt := range tests {
+		ppu := newTestPPU()
+		ppu.scx = tt.scx
+		for _, x := range tt.sprites {
+			ppu.spriteBuffer = append(ppu.spriteBuffer, Sprite{x: x})
+		}
+
+		if ticks := ppu.calcMode3Ticks(); ticks != tt.ticks {
+			t.Errorf("%s: got %d ticks, want %d", tt.name, ticks, tt.ticks)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package gb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// RamSearch narrows down the locations in WRAM, HRAM and every cart RAM bank holding a game variable,
+// by comparing them against a snapshot taken after the last filter
+type RamSearch struct {
+	mmu    *MMU
+	cart   *Cart
+	cheats *Cheats
+	buffer *ebiten.Image
+	x      int
+	y      int
+
+	wide       bool     // search 16-bit little endian values instead of bytes
+	candidates []int    // search locations that passed every filter so far
+	snapshot   []uint16 // value of each candidate as of the last filter
+	cursor     int
+	scroll     int
+
+	focused   bool
+	prompting bool
+	input     []rune
+	status    string
+}
+
+// RamFilter keeps a candidate based on its snapshot and live values
+type RamFilter func(prev uint16, curr uint16) bool
+
+const (
+	RAM_SEARCH_COLS          = 40
+	RAM_SEARCH_SCREEN_WIDTH  = RAM_SEARCH_COLS * DBG_CHAR_WIDTH
+	RAM_SEARCH_SCREEN_HEIGHT = TILE_MAP_SCREEN_HEIGHT
+
+	RAM_SEARCH_FIRST_ROW  = 2
+	RAM_SEARCH_ROWS       = RAM_SEARCH_SCREEN_HEIGHT/DBG_CHAR_HEIGHT - RAM_SEARCH_FIRST_ROW - 2
+	RAM_SEARCH_STATUS_ROW = RAM_SEARCH_FIRST_ROW + RAM_SEARCH_ROWS
+
+	// search locations are numbered WRAM first, then HRAM, then the cart RAM banks back to back
+	SEARCH_HRAM_BASE     = WRAM_SIZE
+	SEARCH_CART_RAM_BASE = SEARCH_HRAM_BASE + HRAM_SIZE
+)
+
+func newRamSearch(mmu *MMU, cart *Cart, cheats *Cheats, x int, y int) *RamSearch {
+	rs := &RamSearch{
+		mmu:    mmu,
+		cart:   cart,
+		cheats: cheats,
+		buffer: ebiten.NewImage(RAM_SEARCH_SCREEN_WIDTH, RAM_SEARCH_SCREEN_HEIGHT),
+		x:      x,
+		y:      y,
+	}
+
+	rs.reset()
+	return rs
+}
+
+// reset makes every location a candidate again and snapshots their values
+func (rs *RamSearch) reset() {
+	rs.candidates = rs.candidates[:0]
+	for loc := 0; loc < SEARCH_CART_RAM_BASE+len(rs.cart.ram); loc++ {
+		if rs.valid(loc) {
+			rs.candidates = append(rs.candidates, loc)
+		}
+	}
+
+	rs.takeSnapshot()
+	rs.cursor = 0
+	rs.status = fmt.Sprintf("snapshot of %d locations", len(rs.candidates))
+}
+
+func (rs *RamSearch) takeSnapshot() {
+	rs.snapshot = rs.snapshot[:0]
+	for _, loc := range rs.candidates {
+		rs.snapshot = append(rs.snapshot, rs.value(loc))
+	}
+}
+
+// valid reports whether a value of the current width starting at loc stays inside one memory region
+func (rs *RamSearch) valid(loc int) bool {
+	if !rs.wide {
+		return true
+	}
+
+	last := loc + 1
+	switch {
+	case loc < SEARCH_HRAM_BASE:
+		return last < SEARCH_HRAM_BASE
+	case loc < SEARCH_CART_RAM_BASE:
+		return last < SEARCH_CART_RAM_BASE
+	default:
+		return (last-SEARCH_CART_RAM_BASE)%RAM_BANK_SIZE != 0 && last < SEARCH_CART_RAM_BASE+len(rs.cart.ram)
+	}
+}
+
+func (rs *RamSearch) readByte(loc int) uint8 {
+	switch {
+	case loc < SEARCH_HRAM_BASE:
+		return rs.mmu.read(WRAM_BASE + uint16(loc))
+	case loc < SEARCH_CART_RAM_BASE:
+		return rs.mmu.read(HRAM_BASE + uint16(loc-SEARCH_HRAM_BASE))
+	default:
+		return rs.cart.ram[loc-SEARCH_CART_RAM_BASE]
+	}
+}
+
+func (rs *RamSearch) value(loc int) uint16 {
+	if rs.wide {
+		return uint16(rs.readByte(loc)) | uint16(rs.readByte(loc+1))<<8
+	}
+
+	return uint16(rs.readByte(loc))
+}
+
+// busAddr returns where loc shows up on the bus, and the cart RAM bank it is in
+func (rs *RamSearch) busAddr(loc int) (uint16, int) {
+	switch {
+	case loc < SEARCH_HRAM_BASE:
+		return WRAM_BASE + uint16(loc), -1
+	case loc < SEARCH_CART_RAM_BASE:
+		return HRAM_BASE + uint16(loc-SEARCH_HRAM_BASE), -1
+	default:
+		off := loc - SEARCH_CART_RAM_BASE
+		return EXT_RAM_BASE + uint16(off%RAM_BANK_SIZE), off / RAM_BANK_SIZE
+	}
+}
+
+func (rs *RamSearch) label(loc int) string {
+	addr, bank := rs.busAddr(loc)
+	if bank >= 0 {
+		return fmt.Sprintf("%02X:%04X", bank, addr)
+	}
+
+	return fmt.Sprintf("   %04X", addr)
+}
+
+func (rs *RamSearch) filter(name string, keep RamFilter) {
+	kept := 0
+	for i, loc := range rs.candidates {
+		if keep(rs.snapshot[i], rs.value(loc)) {
+			rs.candidates[kept] = loc
+			kept++
+		}
+	}
+
+	rs.candidates = rs.candidates[:kept]
+	rs.takeSnapshot()
+	rs.cursor = min(rs.cursor, max(kept-1, 0))
+	rs.status = fmt.Sprintf("%s: %d left", name, kept)
+}
+
+// freeze turns the selected candidate into GameShark codes holding its current value and adds them to the cheats file,
+// enabled straight away to freeze the value or disabled to be turned on later
+func (rs *RamSearch) freeze(enabled bool) {
+	if len(rs.candidates) == 0 {
+		rs.status = "no candidate selected"
+		return
+	}
+
+	loc := rs.candidates[rs.cursor]
+	width := 1
+	if rs.wide {
+		width = 2
+	}
+
+	for i := 0; i < width; i++ {
+		addr, bank := rs.busAddr(loc + i)
+		bankType := uint8(0x01)
+		if bank >= 0 {
+			bankType = GAMESHARK_EXT_RAM_BANK | uint8(bank)
+		}
+
+		code := fmt.Sprintf("%02X%02X%02X%02X", bankType, rs.readByte(loc+i), uint8(addr), uint8(addr>>8))
+		rs.cheats.add(&Cheat{Name: "RAM search " + strings.TrimSpace(rs.label(loc+i)), Code: code, Enabled: enabled})
+	}
+
+	rs.cheats.save()
+	if enabled {
+		rs.status = "froze " + strings.TrimSpace(rs.label(loc))
+	} else {
+		rs.status = "added cheat for " + strings.TrimSpace(rs.label(loc))
+	}
+}
+
+func (rs *RamSearch) handleInput() {
+	_, _, inside := cursorIn(rs.x, rs.y, RAM_SEARCH_SCREEN_WIDTH, RAM_SEARCH_SCREEN_HEIGHT)
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		rs.focused = inside
+	}
+
+	if !rs.focused {
+		return
+	}
+
+	if rs.prompting {
+		rs.handlePrompt()
+		return
+	}
+
+	switch {
+	case keyRepeated(ebiten.KeyArrowUp):
+		rs.cursor = max(rs.cursor-1, 0)
+	case keyRepeated(ebiten.KeyArrowDown):
+		rs.cursor = max(min(rs.cursor+1, len(rs.candidates)-1), 0)
+	}
+
+	for _, r := range ebiten.AppendInputChars(nil) {
+		switch r {
+		case 's', 'S':
+			rs.reset()
+		case 'w', 'W':
+			rs.wide = !rs.wide
+			rs.reset()
+		case '=':
+			rs.filter("equal", func(prev uint16, curr uint16) bool { return curr == prev })
+		case 'c', 'C':
+			rs.filter("changed", func(prev uint16, curr uint16) bool { return curr != prev })
+		case '+':
+			rs.filter("increased", func(prev uint16, curr uint16) bool { return curr > prev })
+		case '-':
+			rs.filter("decreased", func(prev uint16, curr uint16) bool { return curr < prev })
+		case 'v', 'V':
+			rs.prompting = true
+			rs.input = rs.input[:0]
+		case 'f', 'F':
+			rs.freeze(true)
+		case 'a', 'A':
+			rs.freeze(false)
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		rs.focused = false
+	}
+}
+
+func (rs *RamSearch) handlePrompt() {
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if _, ok := hexDigit(r); ok || r == 'x' || r == 'X' {
+			rs.input = append(rs.input, r)
+		}
+	}
+
+	if keyRepeated(ebiten.KeyBackspace) && len(rs.input) > 0 {
+		rs.input = rs.input[:len(rs.input)-1]
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		rs.prompting = false
+		return
+	}
+
+	if !inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		return
+	}
+
+	rs.prompting = false
+
+	// decimal by default since that is how games show most values, 0x for hex
+	val, err := strconv.ParseUint(string(rs.input), 0, 16)
+	if err != nil || (!rs.wide && val > 0xFF) {
+		rs.status = "invalid value"
+		return
+	}
+
+	rs.filter(fmt.Sprintf("value %d", val), func(prev uint16, curr uint16) bool { return curr == uint16(val) })
+}
+
+func (rs *RamSearch) draw(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	rs.buffer.Fill(dbgBackground)
+
+	width := "8-bit"
+	if rs.wide {
+		width = "16-bit"
+	}
+	dbgPrint(rs.buffer, fmt.Sprintf("RAM SEARCH %s  %d candidates", width, len(rs.candidates)), 0, 0)
+	dbgPrint(rs.buffer, "   ADDR  PREV  LIVE", 0, 1)
+
+	rs.scroll = min(rs.scroll, rs.cursor)
+	rs.scroll = max(rs.scroll, rs.cursor-RAM_SEARCH_ROWS+1)
+
+	for row := 0; row < RAM_SEARCH_ROWS && rs.scroll+row < len(rs.candidates); row++ {
+		i := rs.scroll + row
+		loc := rs.candidates[i]
+
+		if i == rs.cursor {
+			dbgFillCell(rs.buffer, 0, RAM_SEARCH_FIRST_ROW+row, RAM_SEARCH_COLS, dbgCursorColor)
+		}
+
+		prev, curr := rs.snapshot[i], rs.value(loc)
+		if prev != curr {
+			dbgFillCell(rs.buffer, 14, RAM_SEARCH_FIRST_ROW+row, 5, dbgHighlight)
+		}
+
+		dbgPrint(rs.buffer, fmt.Sprintf("%s %5d %5d", rs.label(loc), prev, curr), 0, RAM_SEARCH_FIRST_ROW+row)
+	}
+
+	if rs.prompting {
+		dbgPrint(rs.buffer, "value: "+string(rs.input)+"_", 0, RAM_SEARCH_STATUS_ROW)
+	} else {
+		dbgPrint(rs.buffer, rs.status, 0, RAM_SEARCH_STATUS_ROW)
+	}
+
+	dbgPrint(rs.buffer, "S snap W 8/16 = C + - V filter F/A cheat", 0, RAM_SEARCH_STATUS_ROW+1)
+
+	if rs.focused {
+		vector.StrokeRect(rs.buffer, 0, 0, RAM_SEARCH_SCREEN_WIDTH, RAM_SEARCH_SCREEN_HEIGHT, 1, dbgCursorColor, false)
+	}
+
+	screen.DrawImage(rs.buffer, opt)
+}
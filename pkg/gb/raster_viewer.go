@@ -0,0 +1,198 @@
+package gb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// RasterLog records every PPU register write along with the scanline and dot it happened on, one frame at a time
+type RasterLog struct {
+	events      []RasterEvent
+	startValues [NUM_RASTER_REGS]uint8
+	dropped     int // writes past MAX_RASTER_EVENTS
+
+	// the last completed frame, which is what the viewer shows
+	frameEvents      []RasterEvent
+	frameStartValues [NUM_RASTER_REGS]uint8
+	frameDropped     int
+}
+
+type RasterEvent struct {
+	addr uint16
+	val  uint8
+	line uint8 // the scanline being drawn, not LY which already reads 0 for most of line 153
+	dot  uint16
+}
+
+// RasterViewer plots the value of a PPU register on every scanline of the last frame
+type RasterViewer struct {
+	log    *RasterLog
+	buffer *ebiten.Image
+	x      int
+	y      int
+
+	reg       int
+	lineVals  [SCANLINES_PER_FRAME]uint8
+	lineWrote [SCANLINES_PER_FRAME]bool
+}
+
+const (
+	NUM_RASTER_REGS       = WX_ADDR - LCDC_ADDR + 1
+	MAX_RASTER_EVENTS     = 0x1000
+	RASTER_LINE_HEIGHT    = 2
+	RASTER_BAR_WIDTH      = 128 // register values are plotted at half scale
+	RASTER_TIMELINE_X     = RASTER_BAR_WIDTH + 8
+	RASTER_TIMELINE_SCALE = 4 // dots per pixel
+
+	RASTER_VIEWER_SCREEN_WIDTH  = RASTER_TIMELINE_X + TICKS_PER_SCANLINE/RASTER_TIMELINE_SCALE + 6
+	RASTER_VIEWER_PLOT_HEIGHT   = SCANLINES_PER_FRAME * RASTER_LINE_HEIGHT
+	RASTER_VIEWER_SCREEN_HEIGHT = 2*DBG_CHAR_HEIGHT + RASTER_VIEWER_PLOT_HEIGHT
+)
+
+var rasterRegNames = [NUM_RASTER_REGS]string{"LCDC", "STAT", "SCY", "SCX", "LY", "LYC", "DMA", "BGP", "OBP0", "OBP1", "WY", "WX"}
+
+var (
+	dbgBarColor   = hexToRGBA(0x707070)
+	dbgOtherColor = hexToRGBA(0x505050)
+)
+
+func newRasterLog() *RasterLog {
+	return &RasterLog{
+		events:      make([]RasterEvent, 0, MAX_RASTER_EVENTS),
+		frameEvents: make([]RasterEvent, 0, MAX_RASTER_EVENTS),
+	}
+}
+
+func (rl *RasterLog) record(addr uint16, val uint8, line uint8, dot int) {
+	if len(rl.events) >= MAX_RASTER_EVENTS {
+		rl.dropped++
+		return
+	}
+
+	rl.events = append(rl.events, RasterEvent{addr: addr, val: val, line: line, dot: uint16(dot)})
+}
+
+func (rl *RasterLog) endFrame(ppu *PPU) {
+	rl.frameEvents, rl.events = rl.events, rl.frameEvents[:0]
+	rl.frameStartValues = rl.startValues
+	rl.frameDropped, rl.dropped = rl.dropped, 0
+
+	for i := range rl.startValues {
+		rl.startValues[i] = ppu.read(LCDC_ADDR + uint16(i))
+	}
+}
+
+func newRasterViewer(log *RasterLog, x int, y int) *RasterViewer {
+	return &RasterViewer{
+		log:    log,
+		buffer: ebiten.NewImage(RASTER_VIEWER_SCREEN_WIDTH, RASTER_VIEWER_SCREEN_HEIGHT),
+		x:      x,
+		y:      y,
+		reg:    int(SCX_ADDR - LCDC_ADDR),
+	}
+}
+
+func (rv *RasterViewer) handleInput() {
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return
+	}
+
+	// clicking the header cycles through the registers
+	if _, _, inside := cursorIn(rv.x, rv.y, RASTER_VIEWER_SCREEN_WIDTH, DBG_CHAR_HEIGHT); inside {
+		rv.reg = (rv.reg + 1) % NUM_RASTER_REGS
+		if LCDC_ADDR+uint16(rv.reg) == LY_ADDR {
+			rv.reg++
+		}
+	}
+}
+
+func (rv *RasterViewer) regAddr() uint16 {
+	return LCDC_ADDR + uint16(rv.reg)
+}
+
+// computeLines works out the value of the selected register at the start of every scanline
+func (rv *RasterViewer) computeLines() {
+	val := rv.log.frameStartValues[rv.reg]
+	events := rv.log.frameEvents
+
+	for line := 0; line < SCANLINES_PER_FRAME; line++ {
+		rv.lineWrote[line] = false
+
+		for len(events) > 0 && int(events[0].line) < line {
+			if events[0].addr == rv.regAddr() {
+				val = events[0].val
+			}
+			events = events[1:]
+		}
+
+		rv.lineVals[line] = val
+	}
+
+	for _, ev := range rv.log.frameEvents {
+		if ev.addr == rv.regAddr() && int(ev.line) < SCANLINES_PER_FRAME {
+			rv.lineWrote[ev.line] = true
+		}
+	}
+}
+
+func (rv *RasterViewer) hoveredLine() (int, bool) {
+	_, cy, inside := cursorIn(rv.x, rv.y+DBG_CHAR_HEIGHT, RASTER_VIEWER_SCREEN_WIDTH, RASTER_VIEWER_PLOT_HEIGHT)
+	return cy / RASTER_LINE_HEIGHT, inside
+}
+
+func (rv *RasterViewer) draw(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	rv.buffer.Fill(dbgBackground)
+	rv.computeLines()
+
+	header := fmt.Sprintf("RASTER %-4s (click) %d writes", rasterRegNames[rv.reg], len(rv.log.frameEvents))
+	if rv.log.frameDropped > 0 {
+		// the log filled up, so the frame is missing its last writes
+		header = fmt.Sprintf("RASTER %-4s %d writes +%d LOST", rasterRegNames[rv.reg], len(rv.log.frameEvents), rv.log.frameDropped)
+	}
+	dbgPrint(rv.buffer, header, 0, 0)
+
+	for line := 0; line < SCANLINES_PER_FRAME; line++ {
+		y := float32(DBG_CHAR_HEIGHT + line*RASTER_LINE_HEIGHT)
+
+		clr := dbgBarColor
+		if rv.lineWrote[line] {
+			clr = dbgHighlight
+		}
+		vector.DrawFilledRect(rv.buffer, 0, y, float32(rv.lineVals[line])/2+1, RASTER_LINE_HEIGHT, clr, false)
+	}
+
+	// timeline of when in each scanline the writes happened, the selected register stands out from the rest
+	vector.StrokeLine(rv.buffer, RASTER_TIMELINE_X-2, DBG_CHAR_HEIGHT, RASTER_TIMELINE_X-2, DBG_CHAR_HEIGHT+RASTER_VIEWER_PLOT_HEIGHT, 1, dbgOtherColor, false)
+	vector.StrokeLine(rv.buffer, 0, DBG_CHAR_HEIGHT+GB_SCREEN_HEIGHT*RASTER_LINE_HEIGHT, RASTER_VIEWER_SCREEN_WIDTH, DBG_CHAR_HEIGHT+GB_SCREEN_HEIGHT*RASTER_LINE_HEIGHT, 1, dbgOtherColor, false)
+
+	for _, ev := range rv.log.frameEvents {
+		clr := dbgOtherColor
+		if ev.addr == rv.regAddr() {
+			clr = dbgHighlight
+		}
+
+		x := float32(RASTER_TIMELINE_X + int(ev.dot)/RASTER_TIMELINE_SCALE)
+		y := float32(DBG_CHAR_HEIGHT + int(ev.line)*RASTER_LINE_HEIGHT)
+		vector.DrawFilledRect(rv.buffer, x, y, 2, RASTER_LINE_HEIGHT, clr, false)
+	}
+
+	if line, ok := rv.hoveredLine(); ok && line < SCANLINES_PER_FRAME {
+		var info strings.Builder
+		fmt.Fprintf(&info, "LY %3d %s=%02X", line, rasterRegNames[rv.reg], rv.lineVals[line])
+
+		for _, ev := range rv.log.frameEvents {
+			if int(ev.line) == line {
+				fmt.Fprintf(&info, " %s=%02X@%d", rasterRegNames[ev.addr-LCDC_ADDR], ev.val, ev.dot)
+			}
+		}
+
+		ebitenutil.DebugPrintAt(rv.buffer, info.String(), 0, DBG_CHAR_HEIGHT+RASTER_VIEWER_PLOT_HEIGHT)
+	}
+
+	screen.DrawImage(rv.buffer, opt)
+}
@@ -0,0 +1,172 @@
+package gb
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// RebindScreen is an overlay on top of the game screen for changing the input bindings, emulation is paused while it is open
+type RebindScreen struct {
+	input  *InputConfig
+	buffer *ebiten.Image
+
+	open      bool
+	cursor    int
+	scroll    int // first action shown, the list can be longer than the screen
+	capturing bool
+
+	keys     []ebiten.Key
+	buttons  []ebiten.StandardGamepadButton
+	gamepads []ebiten.GamepadID
+}
+
+const (
+	// rendered at twice the resolution of the game screen so the text fits
+	REBIND_SCREEN_SCALE  = 2
+	REBIND_SCREEN_WIDTH  = GB_SCREEN_WIDTH * REBIND_SCREEN_SCALE
+	REBIND_SCREEN_HEIGHT = GB_SCREEN_HEIGHT * REBIND_SCREEN_SCALE
+	REBIND_SCREEN_COLS   = REBIND_SCREEN_WIDTH / DBG_CHAR_WIDTH
+	REBIND_NAME_COLS     = 14
+
+	// leaves room for the title and the help text underneath the list
+	REBIND_VISIBLE_ROWS = REBIND_SCREEN_HEIGHT/DBG_CHAR_HEIGHT - 4
+
+	GAMEPAD_AXIS_CAPTURE = 0.75
+)
+
+func newRebindScreen(input *InputConfig) *RebindScreen {
+	return &RebindScreen{
+		input:  input,
+		buffer: ebiten.NewImage(REBIND_SCREEN_WIDTH, REBIND_SCREEN_HEIGHT),
+	}
+}
+
+func (rs *RebindScreen) show() {
+	rs.open = true
+	rs.capturing = false
+}
+
+func (rs *RebindScreen) close() {
+	rs.open = false
+	rs.input.save()
+}
+
+func (rs *RebindScreen) selected() *InputBinding {
+	return rs.input.bindings[inputActions[rs.cursor]]
+}
+
+func (rs *RebindScreen) handleInput() {
+	rs.gamepads = ebiten.AppendGamepadIDs(rs.gamepads[:0])
+
+	if rs.capturing {
+		rs.capture()
+		return
+	}
+
+	switch {
+	case keyRepeated(ebiten.KeyArrowUp) || rs.gamepadJustPressed(ebiten.StandardGamepadButtonLeftTop):
+		rs.cursor = (rs.cursor + len(inputActions) - 1) % len(inputActions)
+	case keyRepeated(ebiten.KeyArrowDown) || rs.gamepadJustPressed(ebiten.StandardGamepadButtonLeftBottom):
+		rs.cursor = (rs.cursor + 1) % len(inputActions)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter) || rs.gamepadJustPressed(ebiten.StandardGamepadButtonRightBottom):
+		rs.capturing = true
+	case inpututil.IsKeyJustPressed(ebiten.KeyDelete) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		*rs.selected() = InputBinding{}
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape) || rs.gamepadJustPressed(ebiten.StandardGamepadButtonRightRight):
+		rs.close()
+	}
+}
+
+// capture binds the next key, gamepad button or stick direction to the selected action,
+// replacing its keyboard or gamepad bindings respectively
+func (rs *RebindScreen) capture() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		rs.capturing = false
+		return
+	}
+
+	binding := rs.selected()
+
+	rs.keys = inpututil.AppendJustPressedKeys(rs.keys[:0])
+	if len(rs.keys) > 0 {
+		binding.keys = []ebiten.Key{rs.keys[0]}
+		rs.capturing = false
+		return
+	}
+
+	for _, id := range rs.gamepads {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			continue
+		}
+
+		rs.buttons = inpututil.AppendJustPressedStandardGamepadButtons(id, rs.buttons[:0])
+		if len(rs.buttons) > 0 {
+			binding.buttons = []ebiten.StandardGamepadButton{rs.buttons[0]}
+			binding.axes = nil
+			rs.capturing = false
+			return
+		}
+
+		for axis := range gamepadAxisNames {
+			if val := ebiten.StandardGamepadAxisValue(id, axis); val > GAMEPAD_AXIS_CAPTURE || val < -GAMEPAD_AXIS_CAPTURE {
+				binding.buttons = nil
+				binding.axes = []GamepadAxisDir{{axis: axis, positive: val > 0}}
+				rs.capturing = false
+				return
+			}
+		}
+	}
+}
+
+func (rs *RebindScreen) gamepadJustPressed(btn ebiten.StandardGamepadButton) bool {
+	return slices.ContainsFunc(rs.gamepads, func(id ebiten.GamepadID) bool {
+		return inpututil.IsStandardGamepadButtonJustPressed(id, btn)
+	})
+}
+
+func (rs *RebindScreen) draw(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	rs.buffer.Fill(dbgBackground)
+	dbgPrint(rs.buffer, "INPUT BINDINGS", 0, 0)
+
+	// keep the cursor in view
+	rs.scroll = min(rs.scroll, rs.cursor)
+	rs.scroll = max(rs.scroll, rs.cursor-REBIND_VISIBLE_ROWS+1)
+
+	for i, action := range inputActions {
+		if i < rs.scroll || i >= rs.scroll+REBIND_VISIBLE_ROWS {
+			continue
+		}
+		row := i - rs.scroll + 1
+
+		if i == rs.cursor {
+			clr := dbgCursorColor
+			if rs.capturing {
+				clr = dbgHighlight
+			}
+			dbgFillCell(rs.buffer, 0, row, REBIND_SCREEN_COLS, clr)
+		}
+
+		bound := rs.input.bindings[action].String()
+		if i == rs.cursor && rs.capturing {
+			bound = "press a key or gamepad input..."
+		}
+
+		line := fmt.Sprintf("%-*s%s", REBIND_NAME_COLS, action, bound)
+		if len(line) > REBIND_SCREEN_COLS {
+			line = line[:REBIND_SCREEN_COLS-2] + ".."
+		}
+		dbgPrint(rs.buffer, line, 0, row)
+	}
+
+	help := REBIND_VISIBLE_ROWS + 2
+	dbgPrint(rs.buffer, "Enter: rebind  Del: clear", 0, help)
+	dbgPrint(rs.buffer, "Esc: save and close", 0, help+1)
+
+	bufferOpt := ebiten.DrawImageOptions{}
+	bufferOpt.GeoM.Scale(1.0/REBIND_SCREEN_SCALE, 1.0/REBIND_SCREEN_SCALE)
+	bufferOpt.GeoM.Concat(opt.GeoM)
+	screen.DrawImage(rs.buffer, &bufferOpt)
+}
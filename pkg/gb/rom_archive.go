@@ -0,0 +1,124 @@
+package gb
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	ZIP_EXT  = ".zip"
+	GZIP_EXT = ".gz"
+)
+
+var romExts = []string{".gb", ".gbc"}
+
+// readROM reads a ROM file, or one inside of a .zip or .gz archive. Entries of a zip can be picked with
+// archive.zip:path/in/zip.gb, otherwise the first .gb/.gbc entry is used. Along with the ROM it returns the
+// path the ROM would have if it was extracted next to the archive, which save files and patches are named after
+func readROM(filename string) ([]byte, string, error) {
+	if archive, entry, ok := splitZipEntry(filename); ok {
+		return readZipROM(archive, entry)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ZIP_EXT:
+		return readZipROM(filename, "")
+	case GZIP_EXT:
+		return readGzipROM(filename)
+	}
+
+	rom, err := os.ReadFile(filename)
+	return rom, filename, err
+}
+
+// splitZipEntry splits archive.zip:path/in/zip.gb into the archive and the entry
+func splitZipEntry(filename string) (string, string, bool) {
+	idx := strings.LastIndex(strings.ToLower(filename), ZIP_EXT+":")
+	if idx < 0 {
+		return "", "", false
+	}
+
+	split := idx + len(ZIP_EXT)
+	return filename[:split], filename[split+1:], true
+}
+
+func readZipROM(archive string, entry string) ([]byte, string, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+
+	var found *zip.File
+	for _, f := range r.File {
+		if entry != "" && f.Name == entry {
+			found = f
+			break
+		}
+
+		if entry == "" && !f.FileInfo().IsDir() && isROMFile(f.Name) {
+			found = f
+			break
+		}
+	}
+
+	if found == nil {
+		if entry != "" {
+			return nil, "", fmt.Errorf("%s has no entry %s", archive, entry)
+		}
+
+		return nil, "", fmt.Errorf("%s has no .gb or .gbc entry", archive)
+	}
+
+	rc, err := found.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+
+	rom, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not extract %s from %s: %w", found.Name, archive, err)
+	}
+
+	fmt.Printf("Extracted %s from %s\n", found.Name, archive)
+	return rom, filepath.Join(filepath.Dir(archive), path.Base(found.Name)), nil
+}
+
+func readGzipROM(archive string) ([]byte, string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not decompress %s: %w", archive, err)
+	}
+	defer zr.Close()
+
+	rom, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not decompress %s: %w", archive, err)
+	}
+
+	// prefer the original file name stored in the gzip header
+	romPath := strings.TrimSuffix(archive, filepath.Ext(archive))
+	if zr.Name != "" {
+		romPath = filepath.Join(filepath.Dir(archive), filepath.Base(zr.Name))
+	}
+
+	return rom, romPath, nil
+}
+
+func isROMFile(name string) bool {
+	return slices.Contains(romExts, strings.ToLower(path.Ext(name)))
+}
@@ -0,0 +1,166 @@
+package gb
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitZipEntry(t *testing.T) {
+	tests := []struct {
+		filename string
+		archive  string
+		entry    string
+		ok       bool
+	}{
+		{"game.zip:game.gb", "game.zip", "game.gb", true},
+		{"roms/GAME.ZIP:dir/game.gbc", "roms/GAME.ZIP", "dir/game.gbc", true},
+		{"roms.zip.d/game.zip:game.gb", "roms.zip.d/game.zip", "game.gb", true},
+		{`C:\roms\game.zip:game.gb`, `C:\roms\game.zip`, "game.gb", true},
+		{"game.zip:", "game.zip", "", true},
+		{"game.zip", "", "", false},
+		{"game.gb", "", "", false},
+		{`C:\roms\game.gb`, "", "", false},
+	}
+
+	for _, tt := range tests {
+		archive, entry, ok := splitZipEntry(tt.filename)
+		if archive != tt.archive || entry != tt.entry || ok != tt.ok {
+			t.Errorf("splitZipEntry(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.filename, archive, entry, ok, tt.archive, tt.entry, tt.ok)
+		}
+	}
+}
+
+func writeZip(t *testing.T, filename string, entries []string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, name := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadZipROM(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "roms.zip")
+	writeZip(t, archive, []string{"readme.txt", "sub.gb/", "sub/Game.GBC", "other.gb"})
+
+	noROMs := filepath.Join(dir, "docs.zip")
+	writeZip(t, noROMs, []string{"readme.txt"})
+
+	tests := []struct {
+		filename string
+		rom      string
+		romPath  string
+	}{
+		{archive, "sub/Game.GBC", filepath.Join(dir, "Game.GBC")},
+		{archive + ":other.gb", "other.gb", filepath.Join(dir, "other.gb")},
+		{archive + ":readme.txt", "readme.txt", filepath.Join(dir, "readme.txt")},
+	}
+
+	for _, tt := range tests {
+		rom, romPath, err := readROM(tt.filename)
+		if err != nil {
+			t.Errorf("readROM(%q): %v", tt.filename, err)
+			continue
+		}
+
+		if string(rom) != tt.rom || romPath != tt.romPath {
+			t.Errorf("readROM(%q) = %q, %q, want %q, %q", tt.filename, rom, romPath, tt.rom, tt.romPath)
+		}
+	}
+
+	for _, filename := range []string{archive + ":missing.gb", noROMs, filepath.Join(dir, "missing.zip")} {
+		if _, _, err := readROM(filename); err == nil {
+			t.Errorf("readROM(%q) succeeded", filename)
+		}
+	}
+}
+
+func writeGzip(t *testing.T, filename string, name string, data []byte) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = name
+	zw.Write(data)
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadGzipROM(t *testing.T) {
+	dir := t.TempDir()
+
+	named := filepath.Join(dir, "named.gz")
+	writeGzip(t, named, "sub/Original.gb", []byte("named"))
+
+	unnamed := filepath.Join(dir, "game.gb.GZ")
+	writeGzip(t, unnamed, "", []byte("unnamed"))
+
+	tests := []struct {
+		filename string
+		rom      string
+		romPath  string
+	}{
+		{named, "named", filepath.Join(dir, "Original.gb")},
+		{unnamed, "unnamed", filepath.Join(dir, "game.gb")},
+	}
+
+	for _, tt := range tests {
+		rom, romPath, err := readROM(tt.filename)
+		if err != nil {
+			t.Errorf("readROM(%q): %v", tt.filename, err)
+			continue
+		}
+
+		if string(rom) != tt.rom || romPath != tt.romPath {
+			t.Errorf("readROM(%q) = %q, %q, want %q, %q", tt.filename, rom, romPath, tt.rom, tt.romPath)
+		}
+	}
+}
+
+func TestReadCorruptArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	zipped := filepath.Join(dir, "roms.zip")
+	writeZip(t, zipped, []string{"game.gb"})
+	gzipped := filepath.Join(dir, "game.gb.gz")
+	writeGzip(t, gzipped, "", bytes.Repeat([]byte("game"), 64))
+
+	for _, filename := range []string{zipped, gzipped} {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, n := range []int{0, 4, len(data) / 2, len(data) - 1} {
+			if err := os.WriteFile(filename, data[:n], 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := readROM(filename); err == nil {
+				t.Errorf("readROM(%q) truncated to %d bytes succeeded", filename, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package gb
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// datFile is a No-Intro style DAT in the Logiqx XML format
+type datFile struct {
+	Games []datGame `xml:"game"`
+}
+
+type datGame struct {
+	Name string   `xml:"name,attr"`
+	ROMs []datROM `xml:"rom"`
+}
+
+type datROM struct {
+	Name   string `xml:"name,attr"`
+	CRC    string `xml:"crc,attr"`
+	SHA1   string `xml:"sha1,attr"`
+	Status string `xml:"status,attr"`
+}
+
+const DAT_BAD_DUMP = "baddump"
+
+var (
+	revisionTag = regexp.MustCompile(`\(Rev ([^)]+)\)`)
+	// tags used for bad dumps and hacks by No-Intro and GoodTools style DATs
+	badDumpTags = []string{"[b]", "[b1]", "[b2]", "(Bad)"}
+	hackTags    = []string{"(Hack)", "[h]", "[h1]", "[h2]", "[t]", "[T+", "[T-"}
+)
+
+// identify looks the ROM up in a DAT by SHA-1 and CRC32, reporting its canonical title and revision.
+// The lookup is done on the ROM as dumped, before any patches
+func (c *Cart) identify(datFilename string) {
+	data, err := os.ReadFile(datFilename)
+	if err != nil {
+		fmt.Println("WARNING: could not read DAT:", err)
+		return
+	}
+
+	var dat datFile
+	if err := xml.Unmarshal(data, &dat); err != nil {
+		fmt.Printf("WARNING: could not parse DAT %s: %v\n", datFilename, err)
+		return
+	}
+
+	sum := sha1.Sum(c.dump)
+	sha := hex.EncodeToString(sum[:])
+	crc := fmt.Sprintf("%08x", crc32.ChecksumIEEE(c.dump))
+
+	var crcMatch *datGame
+	for i := range dat.Games {
+		game := &dat.Games[i]
+
+		for _, rom := range game.ROMs {
+			if rom.SHA1 != "" && strings.EqualFold(rom.SHA1, sha) {
+				c.reportIdentity(game, rom)
+				return
+			}
+
+			if rom.SHA1 == "" && strings.EqualFold(rom.CRC, crc) && crcMatch == nil {
+				crcMatch = game
+			}
+		}
+	}
+
+	if crcMatch != nil {
+		// only trust a CRC32 match when the DAT has no SHA-1 to check against
+		for _, rom := range crcMatch.ROMs {
+			if strings.EqualFold(rom.CRC, crc) {
+				c.reportIdentity(crcMatch, rom)
+				return
+			}
+		}
+	}
+
+	fmt.Printf("WARNING: ROM (CRC32 %s) is not in %s, it may be a bad dump, a hack or just missing from the DAT\n",
+		strings.ToUpper(crc), datFilename)
+}
+
+func (c *Cart) reportIdentity(game *datGame, rom datROM) {
+	fmt.Println("DAT title: ", game.Name)
+
+	revision := "original release"
+	if m := revisionTag.FindStringSubmatch(game.Name); m != nil {
+		revision = "Rev " + m[1]
+	}
+	fmt.Println("DAT revision: ", revision)
+
+	if rom.Status == DAT_BAD_DUMP || hasTag(game.Name, badDumpTags) {
+		fmt.Println("WARNING: the DAT lists this ROM as a bad dump")
+	}
+
+	if hasTag(game.Name, hackTags) {
+		fmt.Println("WARNING: the DAT lists this ROM as a hack or translation")
+	}
+
+	if c.patched {
+		fmt.Println("Running a patched copy of", game.Name)
+	}
+
+	c.title = game.Name
+}
+
+func hasTag(name string, tags []string) bool {
+	for _, tag := range tags {
+		if strings.Contains(name, tag) {
+			return true
+		}
+	}
+
+	return false
+}
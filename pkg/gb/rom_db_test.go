@@ -0,0 +1,63 @@
+package gb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIdentify(t *testing.T) {
+	dump := []byte("gameboy")
+	// SHA-1 and CRC32 of "gameboy"
+	const sha = "1077b6ae0d975f1e43a58a9af0d265446e7fa471"
+	const crc = "fd606499"
+
+	tests := []struct {
+		name  string
+		dat   string
+		title string
+	}{
+		{"SHA-1", `<datafile>
+			<game name="Other"><rom name="other.gb" crc="00000000" sha1="0000000000000000000000000000000000000000"/></game>
+			<game name="Game (Rev 1)"><rom name="game.gb" crc="` + crc + `" sha1="` + sha + `"/></game>
+		</datafile>`, "Game (Rev 1)"},
+		{"SHA-1 upper case", `<datafile>
+			<game name="Game"><rom name="game.gb" sha1="` + strings.ToUpper(sha) + `"/></game>
+		</datafile>`, "Game"},
+		{"CRC32 without SHA-1", `<datafile>
+			<game name="Game [b]"><rom name="game.gb" crc="` + strings.ToUpper(crc) + `"/></game>
+		</datafile>`, "Game [b]"},
+		{"SHA-1 over an earlier CRC32", `<datafile>
+			<game name="CRC"><rom name="crc.gb" crc="` + crc + `"/></game>
+			<game name="SHA-1"><rom name="sha.gb" sha1="` + sha + `"/></game>
+		</datafile>`, "SHA-1"},
+		{"CRC32 with a different SHA-1", `<datafile>
+			<game name="Collision"><rom name="game.gb" crc="` + crc + `" sha1="0000000000000000000000000000000000000000"/></game>
+		</datafile>`, "TEST"},
+		{"missing", `<datafile><game name="Other"><rom name="other.gb" crc="00000000"/></game></datafile>`, "TEST"},
+		{"empty", ``, "TEST"},
+		{"malformed", `<datafile><game name="Game"><rom crc="` + crc + `"`, "TEST"},
+		{"not a DAT", "\x00\xFF\x00\xFF", "TEST"},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		filename := filepath.Join(dir, "roms.dat")
+		if err := os.WriteFile(filename, []byte(tt.dat), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		c := &Cart{dump: dump, title: "TEST"}
+		c.identify(filename)
+		if c.title != tt.title {
+			t.Errorf("%s: got title %q, want %q", tt.name, c.title, tt.title)
+		}
+	}
+
+	c := &Cart{dump: dump, title: "TEST"}
+	c.identify(filepath.Join(dir, "missing.dat"))
+	if c.title != "TEST" {
+		t.Errorf("missing DAT: got title %q", c.title)
+	}
+}
@@ -0,0 +1,73 @@
+package gb
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	LOGO_BASE          = 0x0104
+	HEADER_CHECKSUM_LO = 0x0134
+	HEADER_CHECKSUM_HI = 0x014C
+	HEADER_CHECKSUM    = 0x014D
+	GLOBAL_CHECKSUM    = 0x014E
+	ROM_SIZE_CODE      = 0x0148
+	MAX_ROM_SIZE_CODE  = 0x08
+	ROM_SIZE_UNIT      = 32 * 1024
+)
+
+// the boot rom refuses to start a cartridge unless this is at 0x0104 - 0x0133
+var nintendoLogo = []byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+// validateHeader checks the logo, both checksums and the ROM size against the header, returning a warning for
+// each problem. None of them stop the game from running, but they usually mean a bad dump or a hack
+func (c *Cart) validateHeader() []string {
+	var warnings []string
+
+	if !bytes.Equal(c.rom[LOGO_BASE:LOGO_BASE+len(nintendoLogo)], nintendoLogo) {
+		warnings = append(warnings, "Nintendo logo does not match, real hardware would lock up in the boot rom")
+	}
+
+	if sum := headerChecksum(c.rom); sum != c.rom[HEADER_CHECKSUM] {
+		warnings = append(warnings, fmt.Sprintf("header checksum is 0x%02X, but the header says 0x%02X "+
+			"(real hardware would lock up in the boot rom)", sum, c.rom[HEADER_CHECKSUM]))
+	}
+
+	stored := uint16(c.rom[GLOBAL_CHECKSUM])<<8 | uint16(c.rom[GLOBAL_CHECKSUM+1])
+	if sum := globalChecksum(c.rom); sum != stored {
+		warnings = append(warnings, fmt.Sprintf("global checksum is 0x%04X, but the header says 0x%04X", sum, stored))
+	}
+
+	// load has already rejected size codes past MAX_ROM_SIZE_CODE
+	if size := ROM_SIZE_UNIT << c.rom[ROM_SIZE_CODE]; size != len(c.rom) {
+		warnings = append(warnings, fmt.Sprintf("ROM is %d KiB, but the header says %d KiB, which is the size it runs as",
+			len(c.rom)/1024, size/1024))
+	}
+
+	return warnings
+}
+
+func headerChecksum(rom []byte) uint8 {
+	sum := uint8(0)
+	for _, b := range rom[HEADER_CHECKSUM_LO : HEADER_CHECKSUM_HI+1] {
+		sum = sum - b - 1
+	}
+
+	return sum
+}
+
+// globalChecksum adds up every byte of the ROM except the checksum itself, the boot rom never checks it
+func globalChecksum(rom []byte) uint16 {
+	sum := uint16(0)
+	for i, b := range rom {
+		if i != GLOBAL_CHECKSUM && i != GLOBAL_CHECKSUM+1 {
+			sum += uint16(b)
+		}
+	}
+
+	return sum
+}
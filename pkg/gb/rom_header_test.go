@@ -0,0 +1,157 @@
+package gb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestROM returns a 32 KiB ROM only cart with a valid header
+func newTestROM() []byte {
+	rom := make([]byte, ROM_SIZE_UNIT)
+	copy(rom[LOGO_BASE:], nintendoLogo)
+	copy(rom[0x0134:], "TEST")
+	fixChecksums(rom)
+	return rom
+}
+
+func fixChecksums(rom []byte) {
+	rom[HEADER_CHECKSUM] = headerChecksum(rom)
+	sum := globalChecksum(rom)
+	rom[GLOBAL_CHECKSUM], rom[GLOBAL_CHECKSUM+1] = uint8(sum>>8), uint8(sum)
+}
+
+func TestHeaderChecksums(t *testing.T) {
+	tests := []struct {
+		rom    []byte
+		header uint8
+		global uint16
+	}{
+		{make([]byte, 0x150), 0xE7, 0x0000},
+		{bytes.Repeat([]byte{0x01}, 0x150), 0xCE, 0x014E},
+		// the checksums themselves are left out of the global checksum
+		{bytes.Repeat([]byte{0xFF}, ROM_SIZE_UNIT), 0x00, 0x7E02},
+	}
+
+	for i, tt := range tests {
+		if sum := headerChecksum(tt.rom); sum != tt.header {
+			t.Errorf("%d: headerChecksum = 0x%02X, want 0x%02X", i, sum, tt.header)
+		}
+
+		if sum := globalChecksum(tt.rom); sum != tt.global {
+			t.Errorf("%d: globalChecksum = 0x%04X, want 0x%04X", i, sum, tt.global)
+		}
+	}
+}
+
+func TestValidateHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(rom []byte) []byte
+		want   []string
+	}{
+		{"valid", func(rom []byte) []byte { return rom }, nil},
+		{"bad logo", func(rom []byte) []byte {
+			rom[LOGO_BASE] ^= 0xFF
+			fixChecksums(rom)
+			return rom
+		}, []string{"Nintendo logo"}},
+		{"bad header checksum", func(rom []byte) []byte {
+			rom[HEADER_CHECKSUM]++
+			sum := globalChecksum(rom)
+			rom[GLOBAL_CHECKSUM], rom[GLOBAL_CHECKSUM+1] = uint8(sum>>8), uint8(sum)
+			return rom
+		}, []string{"header checksum"}},
+		{"bad global checksum", func(rom []byte) []byte {
+			rom[ROM_SIZE_UNIT-1] = 0x01
+			return rom
+		}, []string{"global checksum is 0x"}},
+		{"short dump", func(rom []byte) []byte {
+			rom[ROM_SIZE_CODE] = 0x01
+			fixChecksums(rom)
+			return rom
+		}, []string{"ROM is 32 KiB, but the header says 64 KiB"}},
+	}
+
+	for _, tt := range tests {
+		c := &Cart{rom: tt.modify(newTestROM())}
+		warnings := c.validateHeader()
+
+		if len(warnings) != len(tt.want) {
+			t.Errorf("%s: got warnings %q, want %q", tt.name, warnings, tt.want)
+			continue
+		}
+
+		for i, warning := range warnings {
+			if !strings.Contains(warning, tt.want[i]) {
+				t.Errorf("%s: got warning %q, want %q", tt.name, warning, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestLoadShortROM(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "short.gb")
+	if err := os.WriteFile(filename, newTestROM()[:0x14F], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var c Cart
+	if err := c.load(filename, nil); err == nil || !strings.Contains(err.Error(), "too small") {
+		t.Errorf("got error %v, want a ROM that is too small", err)
+	}
+}
+
+func TestLoadROMSize(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		code uint8
+		want int
+	}{
+		{"short dump", 0x150, 0x00, MIN_ROM_SIZE},
+		{"missing banks", ROM_SIZE_UNIT, 0x02, 4 * ROM_SIZE_UNIT},
+		{"overdump", 2 * ROM_SIZE_UNIT, 0x00, ROM_SIZE_UNIT},
+	}
+
+	for _, tt := range tests {
+		rom := append(newTestROM(), make([]byte, ROM_SIZE_UNIT)...)[:tt.size]
+		rom[ROM_SIZE_CODE] = tt.code
+
+		filename := filepath.Join(t.TempDir(), "rom.gb")
+		if err := os.WriteFile(filename, rom, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var c Cart
+		if err := c.load(filename, nil); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if len(c.rom) != tt.want || int(c.romSize) != tt.want {
+			t.Errorf("%s: ROM is 0x%X bytes and the cart 0x%X, want 0x%X", tt.name, len(c.rom), c.romSize, tt.want)
+		}
+
+		if data := c.read(ROM_TOP); tt.size <= ROM_TOP && data != 0xFF {
+			t.Errorf("%s: read 0x%02X past the end of the dump, want 0xFF", tt.name, data)
+		}
+	}
+}
+
+func TestLoadUnknownROMSize(t *testing.T) {
+	rom := newTestROM()
+	rom[ROM_SIZE_CODE] = MAX_ROM_SIZE_CODE + 1
+
+	filename := filepath.Join(t.TempDir(), "rom.gb")
+	if err := os.WriteFile(filename, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var c Cart
+	if err := c.load(filename, nil); err == nil || !strings.Contains(err.Error(), "unknown ROM size code 0x09") {
+		t.Errorf("got error %v, want an unknown ROM size code", err)
+	}
+}
@@ -0,0 +1,333 @@
+package gb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Soft-patching applies IPS, UPS or BPS patches to the ROM image in memory, leaving the ROM file untouched
+
+const (
+	IPS_MAGIC = "PATCH"
+	IPS_EOF   = 0x454F46 // "EOF"
+	UPS_MAGIC = "UPS1"
+	BPS_MAGIC = "BPS1"
+
+	// UPS and BPS end with the CRC32 of the source, the target and the rest of the patch
+	PATCH_FOOTER_SIZE = 12
+
+	// sizes and offsets in a patch never get near this, anything bigger is a corrupt number
+	MAX_PATCH_VARINT = 1 << 24
+)
+
+var patchExts = []string{".ips", ".ups", ".bps"}
+
+var (
+	errPatchTruncated = errors.New("patch is truncated")
+	errPatchVarint    = errors.New("patch has an out of range size or offset")
+)
+
+// applyPatch works out the format of patch from its header and returns the patched copy of rom
+func applyPatch(rom []byte, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte(IPS_MAGIC)):
+		return applyIPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte(UPS_MAGIC)):
+		return applyUPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte(BPS_MAGIC)):
+		return applyBPS(rom, patch)
+	default:
+		return nil, errors.New("not an IPS, UPS or BPS patch")
+	}
+}
+
+func applyIPS(rom []byte, patch []byte) ([]byte, error) {
+	out := bytes.Clone(rom)
+	r := &patchReader{data: patch, pos: len(IPS_MAGIC)}
+
+	for {
+		offset, err := r.uint24()
+		if err != nil {
+			return nil, err
+		}
+
+		if offset == IPS_EOF {
+			break
+		}
+
+		size, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+
+		var data []byte
+		if size == 0 {
+			// run length encoded record
+			count, err := r.uint16()
+			if err != nil {
+				return nil, err
+			}
+
+			val, err := r.bytes(1)
+			if err != nil {
+				return nil, err
+			}
+
+			data = bytes.Repeat(val, int(count))
+		} else if data, err = r.bytes(int(size)); err != nil {
+			return nil, err
+		}
+
+		if end := int(offset) + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	// some patches truncate the ROM after the EOF marker
+	if size, err := r.uint24(); err == nil && int(size) < len(out) {
+		out = out[:size]
+	}
+
+	return out, nil
+}
+
+func applyUPS(rom []byte, patch []byte) ([]byte, error) {
+	if len(patch) < len(UPS_MAGIC)+PATCH_FOOTER_SIZE {
+		return nil, errPatchTruncated
+	}
+
+	dstCRC, err := checkPatchFooter(rom, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &patchReader{data: patch[:len(patch)-PATCH_FOOTER_SIZE], pos: len(UPS_MAGIC)}
+
+	srcSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+
+	dstSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+
+	if srcSize != len(rom) {
+		return nil, fmt.Errorf("patch expects a %d byte ROM, got %d bytes", srcSize, len(rom))
+	}
+
+	out := make([]byte, dstSize)
+	copy(out, rom)
+
+	pos := 0
+	for r.pos < len(r.data) {
+		skip, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		pos += skip
+
+		// XOR bytes up to and including a terminating 0
+		for {
+			x, err := r.bytes(1)
+			if err != nil {
+				return nil, err
+			}
+
+			if pos < len(out) {
+				out[pos] ^= x[0]
+			}
+			pos++
+
+			if x[0] == 0 {
+				break
+			}
+		}
+	}
+
+	return out, checkTargetCRC(out, dstCRC)
+}
+
+func applyBPS(rom []byte, patch []byte) ([]byte, error) {
+	if len(patch) < len(BPS_MAGIC)+PATCH_FOOTER_SIZE {
+		return nil, errPatchTruncated
+	}
+
+	dstCRC, err := checkPatchFooter(rom, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &patchReader{data: patch[:len(patch)-PATCH_FOOTER_SIZE], pos: len(BPS_MAGIC)}
+
+	var header [3]int // source size, target size, metadata size
+	for i := range header {
+		if header[i], err = r.varint(); err != nil {
+			return nil, err
+		}
+	}
+
+	if header[0] != len(rom) {
+		return nil, fmt.Errorf("patch expects a %d byte ROM, got %d bytes", header[0], len(rom))
+	}
+
+	if _, err := r.bytes(header[2]); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, header[1])
+	outPos, srcRel, dstRel := 0, 0, 0
+
+	for r.pos < len(r.data) {
+		data, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+
+		cmd, length := data&3, (data>>2)+1
+		if outPos+length > len(out) {
+			return nil, errors.New("patch writes past the end of the ROM")
+		}
+
+		switch cmd {
+		case 0: // source read
+			if outPos+length > len(rom) {
+				return nil, errors.New("patch reads past the end of the ROM")
+			}
+			copy(out[outPos:], rom[outPos:outPos+length])
+		case 1: // target read
+			src, err := r.bytes(length)
+			if err != nil {
+				return nil, err
+			}
+			copy(out[outPos:], src)
+		case 2: // source copy
+			if srcRel, err = r.relOffset(srcRel); err != nil {
+				return nil, err
+			}
+			if srcRel < 0 || srcRel+length > len(rom) {
+				return nil, errors.New("patch reads past the end of the ROM")
+			}
+			copy(out[outPos:], rom[srcRel:srcRel+length])
+			srcRel += length
+		case 3: // target copy, byte by byte since the ranges can overlap
+			if dstRel, err = r.relOffset(dstRel); err != nil {
+				return nil, err
+			}
+			if dstRel < 0 || dstRel >= outPos {
+				return nil, errors.New("patch copies from outside the written ROM")
+			}
+			for i := 0; i < length; i++ {
+				out[outPos+i] = out[dstRel]
+				dstRel++
+			}
+		}
+
+		outPos += length
+	}
+
+	return out, checkTargetCRC(out, dstCRC)
+}
+
+// checkPatchFooter validates the patch and source CRC32s, returning the CRC32 the patched ROM should have
+func checkPatchFooter(rom []byte, patch []byte) (uint32, error) {
+	footer := patch[len(patch)-PATCH_FOOTER_SIZE:]
+	srcCRC := binary.LittleEndian.Uint32(footer[0:4])
+	dstCRC := binary.LittleEndian.Uint32(footer[4:8])
+	patchCRC := binary.LittleEndian.Uint32(footer[8:12])
+
+	if crc := crc32.ChecksumIEEE(patch[:len(patch)-4]); crc != patchCRC {
+		return 0, fmt.Errorf("patch is corrupt (CRC32 %08X, expected %08X)", crc, patchCRC)
+	}
+
+	if crc := crc32.ChecksumIEEE(rom); crc != srcCRC {
+		return 0, fmt.Errorf("patch is for a different ROM (CRC32 %08X, expected %08X)", crc, srcCRC)
+	}
+
+	return dstCRC, nil
+}
+
+func checkTargetCRC(out []byte, dstCRC uint32) error {
+	if crc := crc32.ChecksumIEEE(out); crc != dstCRC {
+		return fmt.Errorf("patched ROM is corrupt (CRC32 %08X, expected %08X)", crc, dstCRC)
+	}
+
+	return nil
+}
+
+type patchReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *patchReader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errPatchTruncated
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *patchReader) uint16() (uint16, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (r *patchReader) uint24() (uint32, error) {
+	b, err := r.bytes(3)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+}
+
+// varint reads the variable length numbers used by UPS and BPS, where each continuation adds one
+// to the next 7 bits so that every number has exactly one encoding
+func (r *patchReader) varint() (int, error) {
+	val, shift := 0, 1
+
+	for {
+		b, err := r.bytes(1)
+		if err != nil {
+			return 0, err
+		}
+
+		val += int(b[0]&0x7F) * shift
+		if val > MAX_PATCH_VARINT {
+			return 0, errPatchVarint
+		}
+
+		if b[0]&0x80 != 0 {
+			return val, nil
+		}
+
+		shift <<= 7
+		val += shift
+	}
+}
+
+// relOffset moves a BPS copy offset, the lowest bit of the encoded value is the sign
+func (r *patchReader) relOffset(offset int) (int, error) {
+	data, err := r.varint()
+	if err != nil {
+		return 0, err
+	}
+
+	if data&1 != 0 {
+		return offset - data>>1, nil
+	}
+
+	return offset + data>>1, nil
+}
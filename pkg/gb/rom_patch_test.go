@@ -0,0 +1,201 @@
+package gb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// encodeVarint is the inverse of patchReader.varint
+func encodeVarint(n int) []byte {
+	var out []byte
+	for {
+		x := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(out, 0x80|x)
+		}
+		out = append(out, x)
+		n--
+	}
+}
+
+// withFooter appends the UPS/BPS CRC32 footer for src and dst to body
+func withFooter(body []byte, src []byte, dst []byte) []byte {
+	patch := bytes.Clone(body)
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(src))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(dst))
+	return binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+var (
+	// writes AA BB at 0x02, a run of three CC at 0x05 and a run of two DD at 0x08, growing the ROM by two bytes
+	ipsPatch = []byte("PATCH" +
+		"\x00\x00\x02\x00\x02\xAA\xBB" +
+		"\x00\x00\x05\x00\x00\x00\x03\xCC" +
+		"\x00\x00\x08\x00\x00\x00\x02\xDD" +
+		"EOF")
+	ipsTarget = []byte{0x00, 0x00, 0xAA, 0xBB, 0x00, 0xCC, 0xCC, 0xCC, 0xDD, 0xDD}
+
+	upsSource = []byte("ABCD")
+	upsTarget = []byte("ABXDE")
+	// skip 2 bytes and XOR C into X, then skip 0 and XOR past the end of the source into E
+	upsBody = cat([]byte("UPS1"), encodeVarint(4), encodeVarint(5),
+		encodeVarint(2), []byte{'C' ^ 'X', 0x00},
+		encodeVarint(0), []byte{'E', 0x00})
+
+	bpsSource = []byte("HELLO")
+	bpsTarget = []byte("HELLOxELLLL")
+	// source read HELLO, target read x, source copy EL from offset 1, then an overlapping target copy of the L at 7
+	bpsBody = cat([]byte("BPS1"), encodeVarint(5), encodeVarint(11), encodeVarint(0),
+		encodeVarint(4<<2|0),
+		encodeVarint(0<<2|1), []byte("x"),
+		encodeVarint(1<<2|2), encodeVarint(1<<1),
+		encodeVarint(2<<2|3), encodeVarint(7<<1))
+)
+
+func TestPatchVarint(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0xFF}, 127},
+		{[]byte{0x00, 0x80}, 128},
+		{[]byte{0x7F, 0x80}, 255},
+		{[]byte{0x7F, 0x7F, 0x80}, 32895},
+	}
+
+	for _, tt := range tests {
+		r := &patchReader{data: tt.data}
+		got, err := r.varint()
+		if err != nil || got != tt.want {
+			t.Errorf("varint(% X) = %d, %v, want %d", tt.data, got, err, tt.want)
+		}
+
+		if enc := encodeVarint(tt.want); !bytes.Equal(enc, tt.data) {
+			t.Errorf("encodeVarint(%d) = % X, want % X", tt.want, enc, tt.data)
+		}
+	}
+
+	r := &patchReader{data: bytes.Repeat([]byte{0x7F}, 16)}
+	if _, err := r.varint(); err != errPatchVarint {
+		t.Errorf("overlong varint: got %v, want %v", err, errPatchVarint)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		rom   []byte
+		patch []byte
+		want  []byte
+	}{
+		{"IPS", make([]byte, 8), ipsPatch, ipsTarget},
+		{"IPS truncate", make([]byte, 8), cat(ipsPatch, []byte{0x00, 0x00, 0x04}), ipsTarget[:4]},
+		{"IPS truncate past end", make([]byte, 8), cat(ipsPatch, []byte{0x00, 0x01, 0x00}), ipsTarget},
+		{"UPS", upsSource, withFooter(upsBody, upsSource, upsTarget), upsTarget},
+		{"BPS", bpsSource, withFooter(bpsBody, bpsSource, bpsTarget), bpsTarget},
+	}
+
+	for _, tt := range tests {
+		got, err := applyPatch(tt.rom, tt.patch)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got % X, want % X", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyPatchLeavesROMAlone(t *testing.T) {
+	rom := make([]byte, 8)
+	if _, err := applyPatch(rom, ipsPatch); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rom, make([]byte, 8)) {
+		t.Errorf("ROM was modified: % X", rom)
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	corrupt := withFooter(bpsBody, bpsSource, bpsTarget)
+	corrupt[len(BPS_MAGIC)+3] ^= 0xFF
+
+	tests := []struct {
+		name  string
+		rom   []byte
+		patch []byte
+		want  string
+	}{
+		{"unknown format", upsSource, []byte("NOT A PATCH"), "not an IPS, UPS or BPS patch"},
+		{"IPS without EOF", make([]byte, 8), ipsPatch[:len(ipsPatch)-3], "truncated"},
+		{"IPS truncated record", make([]byte, 8), ipsPatch[:10], "truncated"},
+		{"UPS too short", upsSource, []byte("UPS1"), "truncated"},
+		{"UPS wrong ROM", []byte("ABCE"), withFooter(upsBody, upsSource, upsTarget), "different ROM"},
+		{"UPS wrong target", upsSource, withFooter(upsBody, upsSource, []byte("ABXDF")), "patched ROM is corrupt"},
+		{"BPS corrupt", bpsSource, corrupt, "patch is corrupt"},
+		{"BPS modified ROM", []byte("HELLP"), withFooter(bpsBody, []byte("HELLP"), bpsTarget), "patched ROM is corrupt"},
+		{"BPS wrong size", []byte("HELL"), withFooter(bpsBody, []byte("HELL"), bpsTarget), "expects a 5 byte ROM"},
+	}
+
+	for _, tt := range tests {
+		_, err := applyPatch(tt.rom, tt.patch)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestApplyPatchMalformed feeds truncated and garbled patches with valid CRC32 footers, so they get past the
+// footer checks and into the parsers, which must fail without panicking
+func TestApplyPatchMalformed(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	bodies := []struct {
+		rom  []byte
+		body []byte
+	}{
+		{upsSource, upsBody},
+		{bpsSource, bpsBody},
+		// an overlong number that still terminates, which used to come out negative
+		{upsSource, cat([]byte("UPS1"), encodeVarint(4), bytes.Repeat([]byte{0x7F}, 10), []byte{0x80})},
+		{bpsSource, cat([]byte("BPS1"), encodeVarint(5), encodeVarint(11), encodeVarint(0), bytes.Repeat([]byte{0x7F}, 10), []byte{0x80})},
+		// copies reaching back before the start of the source and target
+		{bpsSource, cat([]byte("BPS1"), encodeVarint(5), encodeVarint(11), encodeVarint(0), encodeVarint(0<<2|2), encodeVarint(1<<1|1))},
+		{bpsSource, cat([]byte("BPS1"), encodeVarint(5), encodeVarint(11), encodeVarint(0), encodeVarint(0<<2|3), encodeVarint(0))},
+	}
+
+	for _, b := range bodies {
+		for n := 0; n <= len(b.body); n++ {
+			applyPatch(b.rom, withFooter(b.body[:n], b.rom, b.rom))
+		}
+
+		for i := 0; i < 1000; i++ {
+			body := bytes.Clone(b.body)
+			for j := rng.Intn(4); j >= 0; j-- {
+				body[4+rng.Intn(len(body)-4)] = byte(rng.Intn(256))
+			}
+			applyPatch(b.rom, withFooter(body, b.rom, b.rom))
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		patch := bytes.Clone(ipsPatch)
+		for j := rng.Intn(4); j >= 0; j-- {
+			patch[5+rng.Intn(len(patch)-5)] = byte(rng.Intn(256))
+		}
+		applyPatch(make([]byte, 8), patch[:rng.Intn(len(patch)+1)])
+	}
+}
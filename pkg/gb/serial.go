@@ -1,7 +1,5 @@
 package gb
 
-import "log"
-
 type SerialPort struct {
 	ic *IntruptController
 	sb uint8
@@ -11,6 +9,8 @@ type SerialPort struct {
 const (
 	SB_ADDR = 0xFF01
 	SC_ADDR = 0xFF02
+
+	SC_UNUSED_MSK = 0x7E
 )
 
 func (s *SerialPort) init(ic *IntruptController) {
@@ -27,9 +27,9 @@ func (s *SerialPort) read(addr uint16) uint8 {
 	case SB_ADDR:
 		return s.sb
 	case SC_ADDR:
-		return s.sc
+		return s.sc | SC_UNUSED_MSK
 	default:
-		log.Fatalf("MMU mapped an illegal read address: 0x%02x to Serial Port", addr)
+		busFault("Serial Port", addr, false)
 		return 0xFF
 	}
 }
@@ -44,6 +44,6 @@ func (s *SerialPort) write(addr uint16, data uint8) {
 			s.ic.requestIntrupt(SERIAL_INTRUPT_BIT)
 		}
 	default:
-		log.Fatalf("MMU mapped an illegal write address: 0x%02x to Serial Port", addr)
+		busFault("Serial Port", addr, true)
 	}
 }
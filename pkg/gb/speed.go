@@ -0,0 +1,103 @@
+package gb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	// fast forward runs as many frames per Update as fit in this budget, leaving time to draw
+	FAST_FORWARD_BUDGET     = time.Second / FPS * 3 / 4
+	MAX_FAST_FORWARD_FRAMES = 32
+)
+
+// each press of the slow motion hotkey moves on to the next speed
+var slowMotionSpeeds = []float64{1, 0.5, 0.25}
+
+func (gb *Gameboy) handleSpeedEvents() {
+	if gb.input.justPressed(ACTION_PAUSE) {
+		gb.paused = !gb.paused
+	}
+
+	if gb.input.justPressed(ACTION_FRAME_ADVANCE) {
+		// advancing from a running game pauses it first
+		gb.advanceFrame = gb.paused
+		gb.paused = true
+	}
+
+	if gb.input.justPressed(ACTION_SPEED) {
+		gb.toggleSpeed()
+	}
+
+	if gb.input.justPressed(ACTION_SLOW_MOTION) {
+		gb.cycleSlowMotion()
+	}
+
+	gb.fastForward = gb.input.pressed(ACTION_FAST_FORWARD)
+}
+
+// setSpeed throttles emulation to speed times real time by changing how often Update is called
+func (gb *Gameboy) setSpeed(speed float64) {
+	gb.speed = speed
+	ebiten.SetTPS(int(FPS * speed))
+}
+
+func (gb *Gameboy) toggleSpeed() {
+	if gb.speed == 2 {
+		gb.setSpeed(1)
+	} else {
+		gb.setSpeed(2)
+	}
+}
+
+func (gb *Gameboy) cycleSlowMotion() {
+	next := slowMotionSpeeds[1]
+	for i, speed := range slowMotionSpeeds {
+		if speed == gb.speed {
+			next = slowMotionSpeeds[(i+1)%len(slowMotionSpeeds)]
+			break
+		}
+	}
+
+	gb.setSpeed(next)
+}
+
+// runFastForward emulates frames unthrottled, sizing each batch by how long recent frames took.
+// Only the last frame of a batch is rendered since the others would never be shown
+func (gb *Gameboy) runFastForward() error {
+	frames := MAX_FAST_FORWARD_FRAMES
+	if gb.frameCost > 0 {
+		frames = min(max(int(FAST_FORWARD_BUDGET/gb.frameCost), 1), MAX_FAST_FORWARD_FRAMES)
+	}
+
+	start := time.Now()
+	defer func() {
+		gb.ppu.skipRender = false
+		if gb.framesRun > 0 {
+			gb.frameCost = time.Since(start) / time.Duration(gb.framesRun)
+		}
+	}()
+
+	for i := 0; i < frames && !gb.crashed; i++ {
+		gb.ppu.skipRender = i != frames-1
+
+		if err := gb.runFrame(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (gb *Gameboy) speedString() string {
+	switch {
+	case gb.paused:
+		return "PAUSED"
+	case gb.fastForward:
+		return fmt.Sprintf("FF %.1fx", float64(gb.framesRun)*ebiten.ActualTPS()/FPS)
+	default:
+		return fmt.Sprintf("%gx", gb.speed)
+	}
+}
@@ -0,0 +1,182 @@
+package gb
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// TileDataViewer renders VRAM tile data independently of how LCDC currently addresses it
+type TileDataViewer struct {
+	ppu      *PPU
+	buffer   *ebiten.Image
+	controls *ebiten.Image
+	x        int
+	y        int
+
+	mode    TileAddrMode
+	palette TileViewPalette
+	live    bool
+	vram    [VRAM_SIZE]uint8 // snapshot that is rendered while live updates are off
+}
+
+type TileAddrMode uint8
+type TileViewPalette uint8
+
+const (
+	TILE_DATA_CONTROLS_HEIGHT = 4 * DBG_CHAR_HEIGHT
+	NUM_TILES                 = 384
+	TILES_PER_ROW             = TILE_DATA_SCREEN_WIDTH / TILE_WIDTH
+
+	// all 384 tiles in VRAM order, or the 256 tile ids as seen by each LCDC tile data area
+	ADDR_MODE_ALL  TileAddrMode = 0
+	ADDR_MODE_8000 TileAddrMode = 1
+	ADDR_MODE_8800 TileAddrMode = 2
+
+	VIEW_PAL_BGP  TileViewPalette = 0
+	VIEW_PAL_OBP0 TileViewPalette = 1
+	VIEW_PAL_OBP1 TileViewPalette = 2
+	VIEW_PAL_RAW  TileViewPalette = 3
+
+	TILE_CONTROL_MODE    = 0
+	TILE_CONTROL_PALETTE = 1
+	TILE_CONTROL_LIVE    = 2
+)
+
+var addrModeNames = [3]string{"ALL", "8000", "8800"}
+var viewPaletteNames = [4]string{"BGP", "OBP0", "OBP1", "RAW"}
+
+var greyscale = [4]color.RGBA{
+	0: hexToRGBA(0xffffff),
+	1: hexToRGBA(0xaaaaaa),
+	2: hexToRGBA(0x555555),
+	3: hexToRGBA(0x000000),
+}
+
+func newTileDataViewer(ppu *PPU, x int, y int) *TileDataViewer {
+	return &TileDataViewer{
+		ppu:      ppu,
+		buffer:   ebiten.NewImage(TILE_DATA_SCREEN_WIDTH, TILE_DATA_SCREEN_HEIGHT),
+		controls: ebiten.NewImage(TILE_DATA_SCREEN_WIDTH, TILE_DATA_CONTROLS_HEIGHT),
+		x:        x,
+		y:        y,
+		live:     true,
+	}
+}
+
+func (tv *TileDataViewer) handleInput() {
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return
+	}
+
+	_, cy, inside := cursorIn(tv.x, tv.y+TILE_DATA_SCREEN_HEIGHT, TILE_DATA_SCREEN_WIDTH, TILE_DATA_CONTROLS_HEIGHT)
+	if !inside {
+		return
+	}
+
+	switch cy / DBG_CHAR_HEIGHT {
+	case TILE_CONTROL_MODE:
+		tv.mode = (tv.mode + 1) % TileAddrMode(len(addrModeNames))
+	case TILE_CONTROL_PALETTE:
+		tv.palette = (tv.palette + 1) % TileViewPalette(len(viewPaletteNames))
+	case TILE_CONTROL_LIVE:
+		tv.live = !tv.live
+		if !tv.live {
+			tv.vram = tv.ppu.vram
+		}
+	}
+}
+
+func (tv *TileDataViewer) colors() [4]color.RGBA {
+	switch tv.palette {
+	case VIEW_PAL_OBP0:
+		return paletteColors(tv.ppu.spPalettes[0])
+	case VIEW_PAL_OBP1:
+		return paletteColors(tv.ppu.spPalettes[1])
+	case VIEW_PAL_RAW:
+		return greyscale
+	default:
+		return paletteColors(tv.ppu.bgPalette)
+	}
+}
+
+// tileAddr returns the address of the tile shown at the given position, in the current addressing mode
+func (tv *TileDataViewer) tileAddr(idx int) (uint16, bool) {
+	switch tv.mode {
+	case ADDR_MODE_8000:
+		if idx >= 256 {
+			return 0, false
+		}
+
+		return VRAM_BASE + uint16(idx*TILE_SIZE), true
+	case ADDR_MODE_8800:
+		if idx >= 256 {
+			return 0, false
+		}
+
+		return 0x9000 + uint16(int(int8(idx))*TILE_SIZE), true
+	default:
+		return VRAM_BASE + uint16(idx*TILE_SIZE), true
+	}
+}
+
+func (tv *TileDataViewer) hoveredTile() (int, bool) {
+	cx, cy, inside := cursorIn(tv.x, tv.y, TILE_DATA_SCREEN_WIDTH, TILE_DATA_SCREEN_HEIGHT)
+	if !inside {
+		return 0, false
+	}
+
+	return (cy/TILE_WIDTH)*TILES_PER_ROW + cx/TILE_WIDTH, true
+}
+
+func (tv *TileDataViewer) draw(screen *ebiten.Image, opt *ebiten.DrawImageOptions) {
+	vram := &tv.ppu.vram
+	if !tv.live {
+		vram = &tv.vram
+	}
+
+	colors := tv.colors()
+	tv.buffer.Fill(dbgBackground)
+
+	for idx := 0; idx < NUM_TILES; idx++ {
+		if addr, ok := tv.tileAddr(idx); ok {
+			writeTileData(tv.buffer, vram, addr, &colors, (idx%TILES_PER_ROW)*TILE_WIDTH, (idx/TILES_PER_ROW)*TILE_WIDTH)
+		}
+	}
+
+	screen.DrawImage(tv.buffer, opt)
+
+	live := "ON"
+	if !tv.live {
+		live = "OFF"
+	}
+
+	info := ""
+	if idx, ok := tv.hoveredTile(); ok {
+		if addr, ok := tv.tileAddr(idx); ok {
+			// ids a tile is reachable by in each tile data area
+			id8000, id8800 := "--", "--"
+			if addr < 0x9000 {
+				id8000 = fmt.Sprintf("%02X", (addr-VRAM_BASE)/TILE_SIZE)
+			}
+			if addr >= 0x8800 {
+				id8800 = fmt.Sprintf("%02X", uint8((int(addr)-0x9000)/TILE_SIZE))
+			}
+
+			info = fmt.Sprintf("%04X 8000:%s 8800:%s", addr, id8000, id8800)
+		}
+	}
+
+	x, y := opt.GeoM.Apply(0, TILE_DATA_SCREEN_HEIGHT)
+	tv.controls.Clear()
+	dbgPrint(tv.controls, "MODE: "+addrModeNames[tv.mode], 0, TILE_CONTROL_MODE)
+	dbgPrint(tv.controls, "PAL:  "+viewPaletteNames[tv.palette], 0, TILE_CONTROL_PALETTE)
+	dbgPrint(tv.controls, "LIVE: "+live, 0, TILE_CONTROL_LIVE)
+	dbgPrint(tv.controls, info, 0, TILE_CONTROL_LIVE+1)
+
+	controlsOpt := ebiten.DrawImageOptions{}
+	controlsOpt.GeoM.Translate(x, y)
+	screen.DrawImage(tv.controls, &controlsOpt)
+}
@@ -1,23 +1,23 @@
 package gb
 
-import (
-	"log"
-
-	"github.com/BeralaWoolies/GameboyGo/pkg/bits"
-)
+import "github.com/BeralaWoolies/GameboyGo/pkg/bits"
 
 type Timer struct {
 	mmu *MMU
 	ic  *IntruptController
 
-	div         uint8
+	// DIV is the upper byte of the 16-bit system counter, TIMA is clocked by a falling edge
+	// of one of its bits (selected by TAC) AND'd with the timer enable bit
+	sysCounter  uint16
 	tima        uint8
 	tma         uint8
 	tac         uint8
-	divCounter  int
-	timaCounter int
+	lastSignal  bool
+	reloadState TimerReloadState
 }
 
+type TimerReloadState uint8
+
 const (
 	DIV_ADDR  = 0xFF04
 	TIMA_ADDR = 0xFF05
@@ -27,13 +27,27 @@ const (
 	TAC_TIMER_ENABLE_BIT = 2
 	TAC_FREQ_DIV_MSK     = 0x3
 	TAC_MSK              = 0x7
+	TAC_UNUSED_MSK       = 0xF8
 
 	HZ_4096   = 0
 	HZ_262144 = 1
 	HZ_65536  = 2
 	HZ_16386  = 3
+
+	// TIMA reads 0 for an M-cycle after overflowing, TMA is loaded into it on the M-cycle after that
+	TIMER_RUNNING    TimerReloadState = 0
+	TIMER_OVERFLOWED TimerReloadState = 1
+	TIMER_RELOADING  TimerReloadState = 2
 )
 
+// system counter bit that clocks TIMA for each TAC frequency
+var timerFreqBits = [4]uint8{
+	HZ_4096:   9,
+	HZ_262144: 3,
+	HZ_65536:  5,
+	HZ_16386:  7,
+}
+
 func (t *Timer) init(mmu *MMU, ic *IntruptController) {
 	t.mmu = mmu
 	t.ic = ic
@@ -57,76 +71,90 @@ func (t *Timer) contains(addr uint16) bool {
 func (t *Timer) write(addr uint16, data uint8) {
 	switch addr {
 	case DIV_ADDR:
-		// not allowed to write to Divider Register
-		t.div = 0
+		// any write resets the whole system counter, which can clock TIMA if the selected bit was set
+		t.sysCounter = 0
+		t.updateSignal()
 	case TIMA_ADDR:
-		t.tima = data
+		switch t.reloadState {
+		case TIMER_OVERFLOWED:
+			// writing during the overflow window cancels the reload and its interrupt
+			t.tima = data
+			t.reloadState = TIMER_RUNNING
+		case TIMER_RELOADING:
+			// TMA wins over writes on the reload cycle
+		default:
+			t.tima = data
+		}
 	case TMA_ADDR:
 		t.tma = data
+		if t.reloadState == TIMER_RELOADING {
+			t.tima = data
+		}
 	case TAC_ADDR:
 		t.tac = data & TAC_MSK
+		t.updateSignal()
 	default:
 		// mmu should never map an illegal address here
-		log.Fatalf("MMU mapped an illegal write address: 0x%02x to Timer", addr)
+		busFault("Timer", addr, true)
 	}
 }
 
 func (t *Timer) read(addr uint16) uint8 {
 	switch addr {
 	case DIV_ADDR:
-		return t.div
+		return bits.HiByte(t.sysCounter)
 	case TIMA_ADDR:
 		return t.tima
 	case TMA_ADDR:
 		return t.tma
 	case TAC_ADDR:
-		return t.tac & TAC_MSK
+		return t.tac | TAC_UNUSED_MSK
 	default:
 		// mmu should never map an illegal address here
-		log.Fatalf("MMU mapped an illegal read address: 0x%02x to Timer", addr)
+		busFault("Timer", addr, false)
 	}
 
 	return 0xFF
 }
 
 func (t *Timer) step(cTicks int) {
-	t.stepDIV(cTicks)
-	if t.timerEnabled() {
-		t.stepTIMA(cTicks)
+	for i := 0; i < cTicks; i += M_CYCLE_TICKS {
+		t.tick()
 	}
 }
 
-func (t *Timer) stepDIV(cTicks int) {
-	t.divCounter += cTicks
-	if t.divCounter >= 256 {
-		t.divCounter -= 256
-		t.div++
+// tick advances the timer by one M-cycle
+func (t *Timer) tick() {
+	switch t.reloadState {
+	case TIMER_OVERFLOWED:
+		t.tima = t.tma
+		t.ic.requestIntrupt(TIMER_INTRUPT_BIT)
+		t.reloadState = TIMER_RELOADING
+	case TIMER_RELOADING:
+		t.reloadState = TIMER_RUNNING
 	}
+
+	t.sysCounter += M_CYCLE_TICKS
+	t.updateSignal()
 }
 
-func (t *Timer) stepTIMA(cTicks int) {
-	t.timaCounter += cTicks
-
-	ovflowTicks := 0
-	switch freqDiv := t.tac & TAC_FREQ_DIV_MSK; freqDiv {
-	case HZ_4096:
-		ovflowTicks = 1024
-	case HZ_262144:
-		ovflowTicks = 16
-	case HZ_65536:
-		ovflowTicks = 64
-	case HZ_16386:
-		ovflowTicks = 256
+// updateSignal increments TIMA on a falling edge of the selected counter bit AND'd with the enable bit,
+// so resetting DIV or changing TAC can clock TIMA as well
+func (t *Timer) updateSignal() {
+	signal := t.timerEnabled() && (t.sysCounter>>timerFreqBits[t.tac&TAC_FREQ_DIV_MSK])&1 == 1
+
+	if t.lastSignal && !signal {
+		t.incTIMA()
 	}
 
-	for t.timaCounter >= ovflowTicks {
-		t.timaCounter -= ovflowTicks
-		t.tima++
+	t.lastSignal = signal
+}
 
-		if t.tima == 0x00 {
-			t.ic.requestIntrupt(TIMER_INTRUPT_BIT)
-			t.tima = t.tma
-		}
+func (t *Timer) incTIMA() {
+	t.tima++
+
+	if t.tima == 0x00 {
+		t.reloadState = TIMER_OVERFLOWED
 	}
 }
 
@@ -135,9 +163,10 @@ func (t *Timer) timerEnabled() bool {
 }
 
 func (t *Timer) setDivDirect(val uint8) {
+	t.sysCounter = uint16(val) << 8
 	if val == 0xAB {
-		t.divCounter = 0xCC
+		t.sysCounter |= 0xCC
 	}
 
-	t.div = val
+	t.updateSignal()
 }
@@ -0,0 +1,131 @@
+package gb
+
+import "testing"
+
+// newTestTimer returns an enabled timer at 262144 Hz, which clocks TIMA every 4 M-cycles
+func newTestTimer() (*Timer, *IntruptController) {
+	ic := &IntruptController{}
+	t := &Timer{}
+	t.init(nil, ic)
+	t.write(TAC_ADDR, 1<<TAC_TIMER_ENABLE_BIT|HZ_262144)
+	return t, ic
+}
+
+func timerRequested(ic *IntruptController) bool {
+	return ic.intruptFlagReg&(1<<TIMER_INTRUPT_BIT) != 0
+}
+
+// overflow runs the timer up to the M-cycle TIMA overflows on
+func overflow(t *testing.T, timer *Timer) {
+	timer.write(TIMA_ADDR, 0xFF)
+	timer.write(TMA_ADDR, 0x42)
+	for i := 0; i < 4; i++ {
+		timer.tick()
+	}
+
+	if timer.reloadState != TIMER_OVERFLOWED {
+		t.Fatalf("TIMA did not overflow (TIMA 0x%02X, counter 0x%04X)", timer.tima, timer.sysCounter)
+	}
+}
+
+func TestTimerFrequencies(t *testing.T) {
+	tests := []struct {
+		freq    uint8
+		mCycles int
+	}{
+		{HZ_4096, 256},
+		{HZ_262144, 4},
+		{HZ_65536, 16},
+		{HZ_16386, 64},
+	}
+
+	for _, tt := range tests {
+		timer, _ := newTestTimer()
+		timer.write(TAC_ADDR, 1<<TAC_TIMER_ENABLE_BIT|tt.freq)
+
+		for i := 1; i <= 3*tt.mCycles; i++ {
+			timer.tick()
+			if want := uint8(i / tt.mCycles); timer.tima != want {
+				t.Fatalf("TAC %d: TIMA is %d after %d M-cycles, want %d", tt.freq, timer.tima, i, want)
+			}
+		}
+	}
+}
+
+func TestTimerOverflowReload(t *testing.T) {
+	timer, ic := newTestTimer()
+	overflow(t, timer)
+
+	// TIMA reads 0 for an M-cycle before TMA is loaded and the interrupt requested
+	if timer.read(TIMA_ADDR) != 0x00 || timerRequested(ic) {
+		t.Errorf("overflow cycle: TIMA 0x%02X, interrupt %v, want 0x00 and no interrupt", timer.tima, timerRequested(ic))
+	}
+
+	timer.tick()
+	if timer.read(TIMA_ADDR) != 0x42 || !timerRequested(ic) {
+		t.Errorf("reload cycle: TIMA 0x%02X, interrupt %v, want 0x42 and an interrupt", timer.tima, timerRequested(ic))
+	}
+
+	timer.tick()
+	if timer.reloadState != TIMER_RUNNING {
+		t.Errorf("timer still reloading an M-cycle after the reload")
+	}
+}
+
+func TestTimerOverflowWrites(t *testing.T) {
+	tests := []struct {
+		name      string
+		write     func(timer *Timer) // done on the given M-cycle after the overflow
+		after     int
+		tima      uint8
+		interrupt bool
+	}{
+		{"TIMA during overflow cancels the reload", func(timer *Timer) { timer.write(TIMA_ADDR, 0x10) }, 0, 0x10, false},
+		{"TMA during overflow is reloaded", func(timer *Timer) { timer.write(TMA_ADDR, 0x20) }, 0, 0x20, true},
+		{"TIMA during reload is ignored", func(timer *Timer) { timer.write(TIMA_ADDR, 0x10) }, 1, 0x42, true},
+		{"TMA during reload goes to TIMA", func(timer *Timer) { timer.write(TMA_ADDR, 0x20) }, 1, 0x20, true},
+	}
+
+	for _, tt := range tests {
+		timer, ic := newTestTimer()
+		overflow(t, timer)
+
+		for i := 0; i <= 1; i++ {
+			if i == tt.after {
+				tt.write(timer)
+			}
+			timer.tick()
+		}
+
+		// one M-cycle after the reload, and before the next increment
+		if timer.tima != tt.tima || timerRequested(ic) != tt.interrupt {
+			t.Errorf("%s: TIMA 0x%02X, interrupt %v, want 0x%02X and %v",
+				tt.name, timer.tima, timerRequested(ic), tt.tima, tt.interrupt)
+		}
+	}
+}
+
+func TestTimerFallingEdges(t *testing.T) {
+	// the selected counter bit is set two M-cycles in, so anything that drops the signal clocks TIMA
+	tests := []struct {
+		name  string
+		write func(timer *Timer)
+		tima  uint8
+	}{
+		{"DIV reset", func(timer *Timer) { timer.write(DIV_ADDR, 0x00) }, 1},
+		{"timer disabled", func(timer *Timer) { timer.write(TAC_ADDR, HZ_262144) }, 1},
+		{"slower frequency", func(timer *Timer) { timer.write(TAC_ADDR, 1<<TAC_TIMER_ENABLE_BIT|HZ_4096) }, 1},
+		{"TMA write", func(timer *Timer) { timer.write(TMA_ADDR, 0x00) }, 0},
+	}
+
+	for _, tt := range tests {
+		timer, _ := newTestTimer()
+		timer.tick()
+		timer.tick()
+
+		tt.write(timer)
+		if timer.tima != tt.tima {
+			t.Errorf("%s: TIMA is %d, want %d", tt.name, timer.tima, tt.tima)
+		}
+	}
+}